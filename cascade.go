@@ -0,0 +1,333 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// SetDeleteInTransaction delete and rules declared by jmgo:"onDelete" are done in a transaction,
+// it is ignored if context is already in a session. restrict rules are always checked before anything is changed,
+// transaction also prevents referencing documents inserted concurrently and partial changes when a write fails
+func (th *Collection[MODEL, ID]) SetDeleteInTransaction(enable bool) *Collection[MODEL, ID] {
+	th.deleteInTransaction = enable
+	return th
+}
+
+// deleteWithRules apply rules of back references before documents matching query are deleted,
+// documents are deleted physically if hard, documents deleted by cascade are marked as deleted if they have soft delete field
+func (th *Collection[MODEL, ID]) deleteWithRules(ctx context.Context, query any, multi bool, hard bool) (int64, error) {
+	var deleted int64
+	fn := func(ctx context.Context) error {
+		ids, err := findIds(ctx, th.collection, query, multi)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		now := th.now()
+		if err = applyDeleteRules(ctx, th.collection.Database(), th.schema, ids, now); err != nil {
+			return err
+		}
+
+		deleted = 0
+		return eachIdBatch(ids, func(batch bson.A) error {
+			query := bson.M{th.schema.IdDBName(): bson.M{"$in": batch}}
+			if hard {
+				result, err := th.collection.DeleteMany(ctx, query)
+				if err != nil {
+					return err
+				}
+				deleted += result.DeletedCount
+				return nil
+			}
+
+			count, err := deleteByQuery(ctx, th.collection, th.schema, query, true, now)
+			deleted += count
+			return err
+		})
+	}
+
+	if err := th.withDeleteTransaction(ctx, fn); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// withDeleteTransaction call fn in a transaction if it is enabled by SetDeleteInTransaction and context is not in a session
+func (th *Collection[MODEL, ID]) withDeleteTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if th.deleteInTransaction && th.client != nil && mongo.SessionFromContext(ctx) == nil {
+		return th.client.WithTransaction(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+// planDelete find documents matching query and plan rules on them, return query of the found documents by id.
+// query is returned as it is if there are too many documents to be queried by id
+func (th *Collection[MODEL, ID]) planDelete(ctx context.Context, query any, multi bool, visited map[string]bool) (any, []*deleteStep, error) {
+	ids, err := findIds(ctx, th.collection, query, multi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	steps, err := planDeleteRules(ctx, th.collection.Database(), th.schema, ids, visited)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(ids) > deleteBatchSize {
+		return query, steps, nil
+	}
+	return bson.M{th.schema.IdDBName(): bson.M{"$in": ids}}, steps, nil
+}
+
+// deleteRulesOf back references with onDelete rule, sorted by name
+func deleteRulesOf(schema *entity.Entity) []*entity.Relation {
+	var relations []*entity.Relation
+	for _, relation := range schema.Relations {
+		if relation.OnDelete != "" {
+			relations = append(relations, relation)
+		}
+	}
+	sort.Slice(relations, func(i, j int) bool {
+		return relations[i].Name < relations[j].Name
+	})
+	return relations
+}
+
+// deleteBatchSize max number of ids in $in of a query made by rules
+const deleteBatchSize = 1000
+
+// deleteStep change made by a rule when referenced documents are deleted
+type deleteStep struct {
+	relation *entity.Relation
+	target   *entity.Entity
+	field    *entity.EntityField
+	// ids referenced documents
+	ids bson.A
+	// childIds referencing documents deleted by cascade
+	childIds bson.A
+	// children steps of documents deleted by cascade
+	children []*deleteStep
+}
+
+// applyDeleteRules the whole cascade tree is walked and every restrict rule is checked before
+// any referencing document is changed, so that nothing is changed if deletion is restricted
+func applyDeleteRules(ctx context.Context, db *mongo.Database, schema *entity.Entity, ids bson.A, now time.Time) error {
+	steps, err := planDeleteRules(ctx, db, schema, ids, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	return runDeleteSteps(ctx, db, steps, now)
+}
+
+// planDeleteRules find documents changed by rules without changing them.
+// visited documents are skipped, so that cyclic cascade terminates
+func planDeleteRules(ctx context.Context, db *mongo.Database, schema *entity.Entity, ids bson.A, visited map[string]bool) ([]*deleteStep, error) {
+	for _, id := range ids {
+		key, err := refKey(id)
+		if err != nil {
+			return nil, err
+		}
+		visited[schema.Collection+"."+key] = true
+	}
+
+	relations := deleteRulesOf(schema)
+	if len(relations) == 0 || len(ids) == 0 {
+		return nil, nil
+	}
+
+	steps := make([]*deleteStep, 0, len(relations))
+	for _, relation := range relations {
+		target, err := entity.GetOrParse(reflect.New(relation.TargetType).Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		field := target.LookUpField(relation.ForeignField)
+		if field == nil {
+			return nil, errors.WithStack(fmt.Errorf("field %s not found in model %s", relation.ForeignField, target.Name))
+		}
+
+		steps = append(steps, &deleteStep{relation: relation, target: target, field: field, ids: ids})
+	}
+
+	for _, step := range steps {
+		if step.relation.OnDelete != entity.OnDeleteRestrict {
+			continue
+		}
+
+		var count int64
+		err := eachIdBatch(step.ids, func(batch bson.A) error {
+			n, err := db.Collection(step.target.Collection).CountDocuments(ctx, step.queryOf(batch))
+			count += n
+			return errors.WithStack(err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errors.WithStack(&errortype.RestrictedError{Model: schema.Name, Relation: step.relation.Name, Count: count})
+		}
+	}
+
+	for _, step := range steps {
+		if step.relation.OnDelete != entity.OnDeleteCascade {
+			continue
+		}
+
+		err := eachIdBatch(step.ids, func(batch bson.A) error {
+			childIds, err := findIds(ctx, db.Collection(step.target.Collection), step.queryOf(batch), true)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range childIds {
+				key, err := refKey(id)
+				if err != nil {
+					return err
+				}
+				if !visited[step.target.Collection+"."+key] {
+					step.childIds = append(step.childIds, id)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(step.childIds) == 0 {
+			continue
+		}
+
+		if step.children, err = planDeleteRules(ctx, db, step.target, step.childIds, visited); err != nil {
+			return nil, err
+		}
+	}
+
+	return steps, nil
+}
+
+// runDeleteSteps rules of cascaded documents are applied before they are deleted
+func runDeleteSteps(ctx context.Context, db *mongo.Database, steps []*deleteStep, now time.Time) error {
+	for _, step := range steps {
+		collection := db.Collection(step.target.Collection)
+		switch step.relation.OnDelete {
+		case entity.OnDeleteCascade:
+			if len(step.childIds) == 0 {
+				continue
+			}
+
+			if err := runDeleteSteps(ctx, db, step.children, now); err != nil {
+				return err
+			}
+
+			err := eachIdBatch(step.childIds, func(batch bson.A) error {
+				_, err := deleteByQuery(ctx, collection, step.target, bson.M{step.target.IdDBName(): bson.M{"$in": batch}}, true, now)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		case entity.OnDeleteSetNull:
+			err := eachIdBatch(step.ids, func(batch bson.A) error {
+				// reference in slice is pulled
+				update := bson.M{"$set": bson.M{step.field.DBName: nil}}
+				if entity.Indirect(step.field.FieldType).Kind() == reflect.Slice {
+					update = bson.M{"$pull": bson.M{step.field.DBName: bson.M{"$in": batch}}}
+				}
+
+				_, err := collection.UpdateMany(ctx, step.queryOf(batch), update)
+				return errors.WithStack(err)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// queryOf query referencing documents of ids, referencing documents marked as deleted are ignored
+func (th *deleteStep) queryOf(ids bson.A) any {
+	return applyDeletedScope(th.target.SoftDeleteField, deletedScopeExclude, bson.M{th.field.DBName: bson.M{"$in": ids}})
+}
+
+// eachIdBatch call fn with ids in batches, so that $in of a query does not exceed size limit of document
+func eachIdBatch(ids bson.A, fn func(batch bson.A) error) error {
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := fn(ids[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findIds ids of documents matching query, at most one id is returned unless multi
+func findIds(ctx context.Context, collection *mongo.Collection, query any, multi bool) (bson.A, error) {
+	opt := options.Find().SetProjection(bson.M{"_id": 1})
+	if !multi {
+		opt.SetLimit(1)
+	}
+
+	cursor, err := collection.Find(ctx, query, opt)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var docs []struct {
+		Id any `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ids := make(bson.A, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Id)
+	}
+	return ids, nil
+}
+
+// deleteByQuery documents are marked as deleted if model has soft delete field
+func deleteByQuery(ctx context.Context, collection *mongo.Collection, schema *entity.Entity, query any, multi bool, now time.Time) (int64, error) {
+	if field := schema.SoftDeleteField; field != nil {
+		var result *mongo.UpdateResult
+		var err error
+		if multi {
+			result, err = collection.UpdateMany(ctx, query, makeDeletedUpdate(field, now))
+		} else {
+			result, err = collection.UpdateOne(ctx, query, makeDeletedUpdate(field, now))
+		}
+		if err != nil {
+			return 0, err
+		}
+		return result.ModifiedCount, nil
+	}
+
+	var result *mongo.DeleteResult
+	var err error
+	if multi {
+		result, err = collection.DeleteMany(ctx, query)
+	} else {
+		result, err = collection.DeleteOne(ctx, query)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
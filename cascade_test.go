@@ -0,0 +1,69 @@
+package jmgo
+
+import (
+	"errors"
+	pkgErrors "github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+type CascadeUser struct {
+	Id       SObjectId       `bson:"_id"`
+	Orders   []*PreloadOrder `bson:"-" jmgo:"refBy=UserId,onDelete=cascade"`
+	Comments []*CascadeOrder `bson:"-" jmgo:"refBy=UserId,onDelete=restrict"`
+	Likes    []CascadeOrder  `bson:"-" jmgo:"refBy=UserId"`
+}
+
+type CascadeOrder struct {
+	Id     SObjectId `bson:"_id"`
+	UserId SObjectId `bson:"userId"`
+}
+
+type InvalidCascadeUser struct {
+	Id     SObjectId       `bson:"_id"`
+	Orders []*CascadeOrder `bson:"-" jmgo:"refBy=UserId,onDelete=drop"`
+}
+
+func Test_DeleteRules(t *testing.T) {
+	schema, err := entity.GetOrParse(&CascadeUser{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	rules := deleteRulesOf(schema)
+	if len(rules) != 2 || rules[0].Name != "Comments" || rules[0].OnDelete != entity.OnDeleteRestrict ||
+		rules[1].Name != "Orders" || rules[1].OnDelete != entity.OnDeleteCascade {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	if _, err = entity.GetOrParse(&InvalidCascadeUser{}); err == nil {
+		t.Fatal("invalid onDelete should be rejected")
+	}
+
+	var restricted *errortype.RestrictedError
+	err = pkgErrors.WithStack(&errortype.RestrictedError{Model: "CascadeUser", Relation: "Comments", Count: 2})
+	if !errors.Is(err, errortype.ErrDeleteRestricted) || !errors.As(err, &restricted) || restricted.Count != 2 {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func Test_EachIdBatch(t *testing.T) {
+	ids := make(bson.A, deleteBatchSize*2+1)
+	var sizes []int
+	err := eachIdBatch(ids, func(batch bson.A) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != deleteBatchSize || sizes[1] != deleteBatchSize || sizes[2] != 1 {
+		t.Fatalf("unexpected batches %v", sizes)
+	}
+
+	if err = eachIdBatch(nil, func(batch bson.A) error { return errors.New("called") }); err != nil {
+		t.Fatalf("empty ids should not be batched, %v", err)
+	}
+}
@@ -5,16 +5,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"time"
 )
 
 type ClientConfig struct {
 	Validate ValidateFunc
-	Opts     []*options.ClientOptions
+	// Now clock used to fill timestamps, time.Now is used if it is nil
+	Now  func() time.Time
+	Opts []*options.ClientOptions
 }
 
 type Client struct {
 	client   *mongo.Client
 	Validate ValidateFunc
+	Now      func() time.Time
 }
 
 func NewClient(config ClientConfig) (*Client, error) {
@@ -22,7 +26,7 @@ func NewClient(config ClientConfig) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{client: c, Validate: config.Validate}, nil
+	return &Client{client: c, Validate: config.Validate, Now: config.Now}, nil
 }
 
 func (c *Client) Client() *mongo.Client {
@@ -0,0 +1,13 @@
+// Command jmgo runs migrations registered by jmgo.Register.
+//
+//	jmgo -uri mongodb://localhost:27017 -db app migrate up|down|status
+//
+// no migration is linked into this command, so it is only useful to list status of applied migrations.
+// to run migrations of your application, build your own command with package migrate, see its doc.
+package main
+
+import "github.com/wsk-go/jmgo/migrate"
+
+func main() {
+	migrate.Main()
+}
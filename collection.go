@@ -20,6 +20,14 @@ type Collection[MODEL any, ID any] struct {
 	collection      *mongo.Collection
 	lastResumeToken bson.Raw
 	client          *Client
+	// use $facet to get total and items in FindWithTotal
+	facetTotal bool
+	// whether to include documents marked as deleted
+	deletedScope deletedScope
+	// generate id before insert
+	idGenerator IdGenerator
+	// delete in transaction when there are rules declared by jmgo:"onDelete"
+	deleteInTransaction bool
 }
 
 func NewCollection[MODEL any, ID any](model MODEL, database *Database, opts ...*options.CollectionOptions) *Collection[MODEL, ID] {
@@ -28,6 +36,7 @@ func NewCollection[MODEL any, ID any](model MODEL, database *Database, opts ...*
 		panic(err)
 	}
 	col := database.db.Collection(schema.Collection, opts...)
+	database.registerSchema(schema)
 
 	return &Collection[MODEL, ID]{
 		collection: col,
@@ -91,13 +100,114 @@ type Page interface {
 }
 
 func (th *Collection[MODEL, ID]) FindPage(ctx context.Context, page Page, filter any, opts ...*options.FindOptions) ([]MODEL, int64, error) {
-	opts = append(opts, options.Find().SetSkip(page.GetLength()).SetSkip(page.GetOffset()))
+	opts = append(opts, options.Find().SetLimit(page.GetLength()).SetSkip(page.GetOffset()))
 	return th.FindWithTotal(ctx, filter, page.GetCountTotal(), opts...)
 }
 
+// FindOneByOption find one by filter, field names in option are names defined in model
+func (th *Collection[MODEL, ID]) FindOneByOption(ctx context.Context, filter any, opts ...*FindOption) (MODEL, error) {
+	option := Merge(opts)
+	if option == nil {
+		return th.FindOneByFilter(ctx, filter)
+	}
+
+	findOneOpts, err := option.makeFindOneOptions(th.schema)
+	if err != nil {
+		var out MODEL
+		return out, err
+	}
+
+	out, err := th.FindOneByFilter(ctx, filter, findOneOpts...)
+	if err != nil {
+		return out, err
+	}
+
+	if err = th.preloadOne(ctx, &out, option.preloads...); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+// FindByOption find by filter, field names in option are names defined in model
+// total will be counted and set if WithTotal is specified in option
+func (th *Collection[MODEL, ID]) FindByOption(ctx context.Context, filter any, opts ...*FindOption) ([]MODEL, error) {
+	option := Merge(opts)
+	if option == nil {
+		return th.Find(ctx, filter)
+	}
+
+	out, _, err := th.findWithTotalByOption(ctx, filter, option.total != nil, option)
+	return out, err
+}
+
+// FindWithTotalByOption get page, field names in option are names defined in model
+func (th *Collection[MODEL, ID]) FindWithTotalByOption(ctx context.Context, filter any, countTotal bool, opts ...*FindOption) ([]MODEL, int64, error) {
+	option := Merge(opts)
+	if option == nil {
+		return th.FindWithTotal(ctx, filter, countTotal)
+	}
+
+	return th.findWithTotalByOption(ctx, filter, countTotal || option.total != nil, option)
+}
+
+// FindPageByOption offset and length of page will override those in option
+func (th *Collection[MODEL, ID]) FindPageByOption(ctx context.Context, page Page, filter any, opts ...*FindOption) ([]MODEL, int64, error) {
+	option := Option().
+		Offset(int(page.GetOffset())).
+		Limit(int(page.GetLength())).
+		Merge(opts)
+	return th.findWithTotalByOption(ctx, filter, page.GetCountTotal() || option.total != nil, option)
+}
+
+func (th *Collection[MODEL, ID]) findWithTotalByOption(ctx context.Context, filter any, countTotal bool, option *FindOption) ([]MODEL, int64, error) {
+	findOpts, err := option.makeFindOption(th.schema)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// facet in option overrides the default of collection
+	facet := th.facetTotal
+	if option.facet != nil {
+		facet = *option.facet
+	}
+
+	var out []MODEL
+	var total int64
+	if countTotal && facet {
+		out, total, err = th.FindWithTotalByFacet(ctx, filter, findOpts...)
+	} else {
+		out, total, err = th.findWithTotalByCount(ctx, filter, countTotal, findOpts...)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err = th.Preload(ctx, out, option.preloads...); err != nil {
+		return nil, 0, err
+	}
+
+	if option.total != nil {
+		*option.total = total
+	}
+
+	return out, total, nil
+}
+
 // FindWithTotal get page
+// total is fetched in the same round-trip with items if facet total is enabled by SetFacetTotal
 func (th *Collection[MODEL, ID]) FindWithTotal(ctx context.Context, filter any, countTotal bool, opts ...*options.FindOptions) ([]MODEL, int64, error) {
 
+	if countTotal && th.facetTotal {
+		return th.FindWithTotalByFacet(ctx, filter, opts...)
+	}
+
+	return th.findWithTotalByCount(ctx, filter, countTotal, opts...)
+}
+
+// count total with CountDocuments and then find
+func (th *Collection[MODEL, ID]) findWithTotalByCount(ctx context.Context, filter any, countTotal bool, opts ...*options.FindOptions) ([]MODEL, int64, error) {
+
 	convertedFilter, _, err := th.convertFilter(filter)
 	if err != nil {
 		return nil, 0, err
@@ -172,7 +282,17 @@ func (th *Collection[MODEL, ID]) mustConvertFilter(filter any) (any, error) {
 	return query, nil
 }
 
+// convertFilter condition to exclude documents marked as deleted is appended, and it is not counted
 func (th *Collection[MODEL, ID]) convertFilter(filter any) (any, int, error) {
+	query, count, err := th.doConvertFilter(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return th.applyDeletedScope(query), count, nil
+}
+
+func (th *Collection[MODEL, ID]) doConvertFilter(filter any) (any, int, error) {
 
 	switch v := filter.(type) {
 	// 原生M,直接返回
@@ -243,11 +363,21 @@ func (th *Collection[MODEL, ID]) fillToQuery(value reflect.Value, filterSchema *
 	return nil
 }
 
+// BulkWrite versioned models are written one by one so that conflict is detected per model, only versions of
+// written models are increased, and ErrVersionConflict is returned with the result if any of them is not matched.
+// rules declared by jmgo:"onDelete" of delete models are applied before models are written, in a transaction if SetDeleteInTransaction
 func (th *Collection[MODEL, ID]) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
 
 	// handle
 	var updateModels []any
-	for _, model := range models {
+	// models with version field for optimistic locking, keyed by index in models
+	versionedModels := map[int]any{}
+	// documents of insert models before they are converted, keyed by index in models
+	insertedModels := map[int]any{}
+	// indexes of delete models whose rules declared by jmgo:"onDelete" are applied before models are written
+	hasDeleteRules := len(deleteRulesOf(th.schema)) > 0
+	var deleteIndexes []int
+	for i, model := range models {
 		switch v := model.(type) {
 		case *mongo.UpdateOneModel:
 			updateModels = append(updateModels, v.Update)
@@ -255,17 +385,24 @@ func (th *Collection[MODEL, ID]) BulkWrite(ctx context.Context, models []mongo.W
 			if err != nil {
 				return nil, err
 			}
-			v.SetFilter(filter)
 
 			err = th.tryCallBeforeUpdateHook(v.Update)
 			if err != nil {
 				return nil, err
 			}
 
-			doc, err := th.mapToUpdate(v.Update)
+			doc, err := th.mapToUpdate(v.Update, nil)
 			if err != nil {
 				return nil, err
 			}
+			th.applyTimestampsToUpdate(doc, v.Update)
+
+			if th.isVersioned(v.Update) {
+				versionedModels[i] = v.Update
+				th.increaseVersionInUpdate(doc)
+				filter = th.addVersionCondition(filter, v.Update)
+			}
+			v.SetFilter(filter)
 			v.SetUpdate(doc)
 		case *mongo.UpdateManyModel:
 			filter, err := th.mustConvertFilter(v.Filter)
@@ -279,47 +416,121 @@ func (th *Collection[MODEL, ID]) BulkWrite(ctx context.Context, models []mongo.W
 				return nil, err
 			}
 
-			doc, err := th.mapToUpdate(v.Update)
+			doc, err := th.mapToUpdate(v.Update, nil)
 			if err != nil {
 				return nil, err
 			}
+			th.applyTimestampsToUpdate(doc, v.Update)
 			v.SetUpdate(doc)
 		case *mongo.DeleteOneModel:
 			filter, err := th.mustConvertFilter(v.Filter)
 			if err != nil {
 				return nil, err
 			}
+			if hasDeleteRules {
+				deleteIndexes = append(deleteIndexes, i)
+			}
 			v.SetFilter(filter)
+
+			// mark as deleted instead
+			if th.schema.SoftDeleteField != nil {
+				models[i] = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(th.makeDeletedUpdate())
+			}
 		case *mongo.DeleteManyModel:
 			filter, err := th.mustConvertFilter(v.Filter)
 			if err != nil {
 				return nil, err
 			}
+			if hasDeleteRules {
+				deleteIndexes = append(deleteIndexes, i)
+			}
 			v.SetFilter(filter)
+
+			// mark as deleted instead
+			if th.schema.SoftDeleteField != nil {
+				models[i] = mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(th.makeDeletedUpdate())
+			}
 		case *mongo.ReplaceOneModel:
 			filter, err := th.mustConvertFilter(v.Filter)
 			if err != nil {
 				return nil, err
 			}
 			v.SetFilter(filter)
+
+			if replacement, ok := v.Replacement.(MODEL); ok {
+				updateModels = append(updateModels, replacement)
+				doc, err := th.mapToReplacement(replacement)
+				if err != nil {
+					return nil, err
+				}
+
+				if th.isVersioned(replacement) {
+					versionedModels[i] = replacement
+					doc = th.increaseVersionInReplacement(doc, replacement)
+					v.SetFilter(th.addVersionCondition(filter, replacement))
+				}
+				v.SetReplacement(doc)
+			}
 		case *mongo.InsertOneModel:
-			err := th.tryCallBeforeSaveHook(v.Document)
+			document := v.Document
+			defaultId := th.idGenerator == nil && th.isIdZero(document)
+
+			// id is not returned by bulk write, so it is generated before insert
+			if err := th.assignId(ctx, document, true); err != nil {
+				return nil, err
+			}
+
+			th.stampOnInsert(document)
+			err := th.tryCallBeforeSaveHook(document)
 			if err != nil {
 				return nil, err
 			}
+
+			// string id is stored as ObjectId as InsertOne does, where id is generated by driver
+			if defaultId {
+				doc, err := th.objectIdDocument(document)
+				if err != nil {
+					return nil, err
+				}
+				v.SetDocument(doc)
+			}
+			insertedModels[i] = document
 		}
 	}
 
 	// write models to mongodb
-	result, err := th.collection.BulkWrite(ctx, models, opts...)
+	var result *mongo.BulkWriteResult
+	var conflicted bool
+	write := func(ctx context.Context) error {
+		var err error
+		result, conflicted, err = th.bulkWrite(ctx, models, versionedModels, opts...)
+		return err
+	}
+
+	var err error
+	if len(deleteIndexes) > 0 {
+		err = th.withDeleteTransaction(ctx, func(ctx context.Context) error {
+			if err := th.applyBulkDeleteRules(ctx, models, deleteIndexes); err != nil {
+				return err
+			}
+			return write(ctx)
+		})
+	} else {
+		err = write(ctx)
+	}
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
+	}
+
+	// the result is returned to tell what has been written
+	if conflicted {
+		return result, errors.WithStack(errortype.ErrVersionConflict)
 	}
 
 	// call hook for insert one and update one
-	for i, model := range models {
-		if insertion, ok := model.(*mongo.InsertOneModel); ok {
-			th.tryCallAfterSaveHook(insertion.Document, result.UpsertedIDs[int64(i)])
+	for i := range models {
+		if document, ok := insertedModels[i]; ok {
+			th.tryCallAfterSaveHook(document, th.idOf(document))
 		}
 	}
 	for _, model := range updateModels {
@@ -328,6 +539,109 @@ func (th *Collection[MODEL, ID]) BulkWrite(ctx context.Context, models []mongo.W
 	return result, nil
 }
 
+// applyBulkDeleteRules plan rules of delete models at indexes and apply them, restrict rules of all delete models
+// are checked before anything is changed. filter of each delete model, which may be replaced by update for soft delete,
+// is replaced by ids of the found documents, so that rules are applied on the deleted ones
+func (th *Collection[MODEL, ID]) applyBulkDeleteRules(ctx context.Context, models []mongo.WriteModel, indexes []int) error {
+	var steps []*deleteStep
+	visited := map[string]bool{}
+	for _, i := range indexes {
+		var filter any
+		multi := false
+		switch v := models[i].(type) {
+		case *mongo.DeleteOneModel:
+			filter = v.Filter
+		case *mongo.DeleteManyModel:
+			filter, multi = v.Filter, true
+		case *mongo.UpdateOneModel:
+			filter = v.Filter
+		case *mongo.UpdateManyModel:
+			filter, multi = v.Filter, true
+		}
+
+		query, planned, err := th.planDelete(ctx, filter, multi, visited)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, planned...)
+
+		switch v := models[i].(type) {
+		case *mongo.DeleteOneModel:
+			v.SetFilter(query)
+		case *mongo.DeleteManyModel:
+			v.SetFilter(query)
+		case *mongo.UpdateOneModel:
+			v.SetFilter(query)
+		case *mongo.UpdateManyModel:
+			v.SetFilter(query)
+		}
+	}
+
+	return runDeleteSteps(ctx, th.collection.Database(), steps, th.now())
+}
+
+// bulkWrite consecutive unversioned models are written in a batch, and each versioned model is written alone,
+// because conflict can only be detected by matched count of the model itself.
+// version of versioned model is increased once it is written, return whether any versioned model is conflicted
+func (th *Collection[MODEL, ID]) bulkWrite(ctx context.Context, models []mongo.WriteModel, versionedModels map[int]any, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, bool, error) {
+	if len(versionedModels) == 0 {
+		result, err := th.collection.BulkWrite(ctx, models, opts...)
+		return result, false, errors.WithStack(err)
+	}
+
+	total := &mongo.BulkWriteResult{UpsertedIDs: map[int64]interface{}{}}
+	conflicted := false
+	write := func(start int, end int) (*mongo.BulkWriteResult, error) {
+		result, err := th.collection.BulkWrite(ctx, models[start:end], opts...)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		total.InsertedCount += result.InsertedCount
+		total.MatchedCount += result.MatchedCount
+		total.ModifiedCount += result.ModifiedCount
+		total.DeletedCount += result.DeletedCount
+		total.UpsertedCount += result.UpsertedCount
+		for i, id := range result.UpsertedIDs {
+			total.UpsertedIDs[i+int64(start)] = id
+		}
+		return result, nil
+	}
+
+	start := 0
+	for i := range models {
+		model, ok := versionedModels[i]
+		if !ok {
+			continue
+		}
+
+		if start < i {
+			if _, err := write(start, i); err != nil {
+				return nil, false, err
+			}
+		}
+
+		result, err := write(i, i+1)
+		if err != nil {
+			return nil, false, err
+		}
+		if result.MatchedCount == 0 {
+			conflicted = true
+		} else {
+			th.increaseModelVersion(model)
+		}
+		start = i + 1
+	}
+
+	if start < len(models) {
+		if _, err := write(start, len(models)); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return total, conflicted, nil
+}
+
 func (th *Collection[MODEL, ID]) NewUpdateOneModel(filter any, model MODEL) *mongo.UpdateOneModel {
 	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(model)
 }
@@ -336,6 +650,18 @@ func (th *Collection[MODEL, ID]) NewUpdateManyModel(filter any, model MODEL) *mo
 	return mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(model)
 }
 
+func (th *Collection[MODEL, ID]) NewUpdateOneModelWith(filter any, update *UpdateBuilder) *mongo.UpdateOneModel {
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update)
+}
+
+func (th *Collection[MODEL, ID]) NewUpdateManyModelWith(filter any, update *UpdateBuilder) *mongo.UpdateManyModel {
+	return mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(update)
+}
+
+func (th *Collection[MODEL, ID]) NewReplaceOneModel(filter any, model MODEL) *mongo.ReplaceOneModel {
+	return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(model)
+}
+
 func (th *Collection[MODEL, ID]) NewInsertOneModel(model MODEL) *mongo.InsertOneModel {
 	return mongo.NewInsertOneModel().SetDocument(model)
 }
@@ -348,7 +674,16 @@ func (th *Collection[MODEL, ID]) NewDeleteManyModel(filter any) *mongo.DeleteMan
 	return mongo.NewDeleteManyModel().SetFilter(filter)
 }
 
+// Aggregate pipeline can be *PipelineBuilder or raw pipeline
 func (th *Collection[MODEL, ID]) Aggregate(ctx context.Context, pipeline any, results any, opts ...*options.AggregateOptions) error {
+	if builder, ok := pipeline.(*PipelineBuilder); ok {
+		stages, err := th.buildPipeline(builder)
+		if err != nil {
+			return err
+		}
+		pipeline = stages
+	}
+
 	cursor, err := th.collection.Aggregate(ctx, pipeline, opts...)
 
 	if err != nil {
@@ -416,6 +751,11 @@ func (th *Collection[MODEL, ID]) mustSchemaField(fieldName string) (*entity.Enti
 // InsertOne inert one
 func (th *Collection[MODEL, ID]) InsertOne(ctx context.Context, model MODEL, opts ...*options.InsertOneOptions) error {
 
+	if err := th.assignId(ctx, model, false); err != nil {
+		return err
+	}
+
+	th.stampOnInsert(model)
 	if err := th.tryCallBeforeSaveHook(model); err != nil {
 		return err
 	}
@@ -425,6 +765,10 @@ func (th *Collection[MODEL, ID]) InsertOne(ctx context.Context, model MODEL, opt
 		return err
 	}
 
+	if err := th.writeBackId(model, result.InsertedID); err != nil {
+		return err
+	}
+
 	th.tryCallAfterSaveHook(model, result.InsertedID)
 
 	return nil
@@ -435,6 +779,11 @@ func (th *Collection[MODEL, ID]) InsertMany(ctx context.Context, models []MODEL,
 
 	var ms = make([]any, 0, len(models))
 	for _, model := range models {
+		if err := th.assignId(ctx, model, false); err != nil {
+			return err
+		}
+
+		th.stampOnInsert(model)
 		err := th.tryCallBeforeSaveHook(model)
 		if err != nil {
 			return err
@@ -448,6 +797,9 @@ func (th *Collection[MODEL, ID]) InsertMany(ctx context.Context, models []MODEL,
 	}
 
 	for i, model := range models {
+		if err := th.writeBackId(model, result.InsertedIDs[i]); err != nil {
+			return err
+		}
 		th.tryCallAfterSaveHook(model, result.InsertedIDs[i])
 	}
 
@@ -460,7 +812,7 @@ func (th *Collection[MODEL, ID]) UpdateOneById(ctx context.Context, id ID, model
 
 func (th *Collection[MODEL, ID]) UpdateOne(ctx context.Context, filter any, model MODEL, opts ...*options.UpdateOptions) (bool, error) {
 
-	result, err := th.doUpdate(ctx, filter, model, false, opts)
+	result, err := th.doUpdate(ctx, filter, model, false, nil, opts)
 	if err != nil {
 		return false, err
 	}
@@ -470,7 +822,29 @@ func (th *Collection[MODEL, ID]) UpdateOne(ctx context.Context, filter any, mode
 
 func (th *Collection[MODEL, ID]) UpdateMany(ctx context.Context, filter any, model MODEL, opts ...*options.UpdateOptions) (int64, error) {
 
-	result, err := th.doUpdate(ctx, filter, model, true, opts)
+	result, err := th.doUpdate(ctx, filter, model, true, nil, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, err
+}
+
+// UpdateOneWith update one by operators of builder
+func (th *Collection[MODEL, ID]) UpdateOneWith(ctx context.Context, filter any, update *UpdateBuilder, opts ...*options.UpdateOptions) (bool, error) {
+
+	result, err := th.doUpdate(ctx, filter, update, false, nil, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, err
+}
+
+// UpdateManyWith update many by operators of builder
+func (th *Collection[MODEL, ID]) UpdateManyWith(ctx context.Context, filter any, update *UpdateBuilder, opts ...*options.UpdateOptions) (int64, error) {
+
+	result, err := th.doUpdate(ctx, filter, update, true, nil, opts)
 	if err != nil {
 		return 0, err
 	}
@@ -478,7 +852,8 @@ func (th *Collection[MODEL, ID]) UpdateMany(ctx context.Context, filter any, mod
 	return result.ModifiedCount, err
 }
 
-func (th *Collection[MODEL, ID]) doUpdate(ctx context.Context, filter any, model any, multi bool, opts []*options.UpdateOptions) (*mongo.UpdateResult, error) {
+// model can be MODEL or *UpdateBuilder
+func (th *Collection[MODEL, ID]) doUpdate(ctx context.Context, filter any, model any, multi bool, selection *fieldSelection, opts []*options.UpdateOptions) (*mongo.UpdateResult, error) {
 
 	err := th.tryCallBeforeUpdateHook(model)
 	if err != nil {
@@ -494,10 +869,20 @@ func (th *Collection[MODEL, ID]) doUpdate(ctx context.Context, filter any, model
 		return nil, errors.WithStack(errortype.ErrFilterNotContainAnyCondition)
 	}
 
-	update, err := th.mapToUpdate(model)
+	update, err := th.mapToUpdate(model, selection)
 	if err != nil {
 		return nil, err
 	}
+	th.applyTimestampsToUpdate(update, model)
+
+	// optimistic locking, version of model is required to match when update one
+	versioned := th.isVersioned(model)
+	if versioned {
+		th.increaseVersionInUpdate(update)
+		if !multi {
+			query = th.addVersionCondition(query, model)
+		}
+	}
 
 	var result *mongo.UpdateResult
 
@@ -511,6 +896,13 @@ func (th *Collection[MODEL, ID]) doUpdate(ctx context.Context, filter any, model
 		if err != nil {
 			return nil, err
 		}
+
+		if versioned {
+			if result.MatchedCount == 0 {
+				return nil, errors.WithStack(errortype.ErrVersionConflict)
+			}
+			th.increaseModelVersion(model)
+		}
 	}
 
 	th.tryCallAfterUpdateHook(model)
@@ -518,50 +910,120 @@ func (th *Collection[MODEL, ID]) doUpdate(ctx context.Context, filter any, model
 	return result, nil
 }
 
-func (th *Collection[MODEL, ID]) mapToUpdate(model any) (bson.M, error) {
+// selection is optional, non-zero fields are set if it is nil
+func (th *Collection[MODEL, ID]) mapToUpdate(model any, selection *fieldSelection) (bson.M, error) {
+	if builder, ok := model.(*UpdateBuilder); ok {
+		return builder.build(th.schema)
+	}
+
+	fields, err := th.mapToFields(model, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{
+		"$set": fields,
+	}, nil
+}
+
+// map fields of model to document, selected fields are always mapped even if it is zero,
+// and non-zero fields are mapped if no field is selected.
+// note that pointer field pointing to zero value is not zero
+func (th *Collection[MODEL, ID]) mapToFields(model any, selection *fieldSelection) (bson.M, error) {
 	value := reflect.ValueOf(model)
 
-	update := bson.M{}
+	selects, omits, err := selection.resolve(th.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := bson.M{}
 	for _, field := range th.schema.Fields {
+		if omits[field.DBName] {
+			continue
+		}
+
 		object, zero := field.ValueOf(value)
-		// continue if field value is zero
-		if zero {
+		if len(selects) > 0 {
+			if !selects[field.DBName] {
+				continue
+			}
+		} else if zero {
+			// continue if field value is zero
 			continue
 		}
 		// handle by the field itself
-		update[field.DBName] = object
+		fields[field.DBName] = object
 	}
 
-	return bson.M{
-		"$set": update,
-	}, nil
+	return fields, nil
 }
 
-func (th *Collection[MODEL, ID]) FindAndModify(ctx context.Context, filter any, document any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
-	return th.collection.FindOneAndUpdate(ctx, filter, document, opts...)
+func (th *Collection[MODEL, ID]) ReplaceOneById(ctx context.Context, id ID, model MODEL, opts ...*options.ReplaceOptions) (bool, error) {
+	return th.ReplaceOne(ctx, bson.M{th.schema.IdDBName(): id}, model, opts...)
 }
 
-func (th *Collection[MODEL, ID]) DeleteOneById(ctx context.Context, id ID) (bool, error) {
-	return th.DeleteOne(ctx, bson.M{th.schema.IdDBName(): id})
-}
-func (th *Collection[MODEL, ID]) DeleteOne(ctx context.Context, filter any) (bool, error) {
+// ReplaceOne replace the whole document except id, return whether a document is matched.
+// createdAt of model must be non-zero, or ErrZeroCreatedAt is returned
+func (th *Collection[MODEL, ID]) ReplaceOne(ctx context.Context, filter any, model MODEL, opts ...*options.ReplaceOptions) (bool, error) {
 
-	query, count, err := th.convertFilter(filter)
+	query, err := th.mustConvertFilter(filter)
 	if err != nil {
 		return false, err
 	}
 
-	if count == 0 {
-		return false, errors.WithStack(errortype.ErrModelTypeNotMatchInCollection)
+	replacement, err := th.mapToReplacement(model)
+	if err != nil {
+		return false, err
 	}
 
-	result, err := th.collection.DeleteOne(ctx, query)
+	// optimistic locking
+	versioned := th.isVersioned(model)
+	if versioned {
+		query = th.addVersionCondition(query, model)
+		replacement = th.increaseVersionInReplacement(replacement, model)
+	}
+
+	result, err := th.collection.ReplaceOne(ctx, query, replacement, opts...)
 	if err != nil {
 		return false, err
 	}
-	return result.DeletedCount > 0, nil
+
+	if versioned {
+		if result.MatchedCount == 0 {
+			return false, errors.WithStack(errortype.ErrVersionConflict)
+		}
+		th.increaseModelVersion(model)
+	}
+
+	th.tryCallAfterUpdateHook(model)
+
+	return result.MatchedCount > 0, nil
+}
+
+// FindAndModify document can be *UpdateBuilder or raw update document
+func (th *Collection[MODEL, ID]) FindAndModify(ctx context.Context, filter any, document any, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	if builder, ok := document.(*UpdateBuilder); ok {
+		update, err := builder.build(th.schema)
+		if err != nil {
+			return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+		}
+		document = update
+	}
+	return th.collection.FindOneAndUpdate(ctx, filter, document, opts...)
 }
 
+func (th *Collection[MODEL, ID]) DeleteOneById(ctx context.Context, id ID) (bool, error) {
+	return th.DeleteOne(ctx, bson.M{th.schema.IdDBName(): id})
+}
+
+// DeleteOne document is marked as deleted if model has soft delete field
+func (th *Collection[MODEL, ID]) DeleteOne(ctx context.Context, filter any) (bool, error) {
+	count, err := th.doDelete(ctx, filter, false)
+	return count > 0, err
+}
+
+// Delete documents are marked as deleted if model has soft delete field
 func (th *Collection[MODEL, ID]) Delete(ctx context.Context, filter any) (bool, error) {
 	count, err := th.doDelete(ctx, filter, true)
 	return count > 0, err
@@ -578,18 +1040,11 @@ func (th *Collection[MODEL, ID]) doDelete(ctx context.Context, filter any, multi
 		return 0, errors.WithStack(errortype.ErrModelTypeNotMatchInCollection)
 	}
 
-	var result *mongo.DeleteResult
-	if multi {
-		result, err = th.collection.DeleteMany(ctx, query)
-	} else {
-		result, err = th.collection.DeleteOne(ctx, query)
+	if len(deleteRulesOf(th.schema)) > 0 {
+		return th.deleteWithRules(ctx, query, multi, false)
 	}
 
-	if err != nil {
-		return 0, err
-	}
-
-	return result.DeletedCount, nil
+	return deleteByQuery(ctx, th.collection, th.schema, query, multi, th.now())
 }
 
 func (th *Collection[MODEL, ID]) EnsureIndex(model *mongo.IndexModel) (string, error) {
@@ -597,6 +1052,8 @@ func (th *Collection[MODEL, ID]) EnsureIndex(model *mongo.IndexModel) (string, e
 }
 
 // listen: 出错直接使用panic
+//
+// Deprecated: Watch can not be stopped and panics on error, use Subscribe instead
 func (th *Collection[MODEL, ID]) Watch(opts *options.ChangeStreamOptions, matchStage bson.D, listen func(stream *mongo.ChangeStream) error) {
 
 	for {
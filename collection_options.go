@@ -0,0 +1,174 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionReport result of EnsureCollection
+type CollectionReport struct {
+	Collection string
+	// Created whether the collection is created
+	Created bool
+	// Mismatches options declared by model but differ from those of existing collection,
+	// existing collection is never changed because most of these options can not be modified
+	Mismatches []string
+}
+
+// options of collection returned by listCollections
+type collectionSpecOptions struct {
+	Capped             bool   `bson:"capped"`
+	Size               *int64 `bson:"size"`
+	Max                *int64 `bson:"max"`
+	ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	TimeSeries         *struct {
+		TimeField   string `bson:"timeField"`
+		MetaField   string `bson:"metaField"`
+		Granularity string `bson:"granularity"`
+	} `bson:"timeseries"`
+	ClusteredIndex bson.Raw `bson:"clusteredIndex"`
+	Collation      *struct {
+		Locale   string `bson:"locale"`
+		Strength int    `bson:"strength"`
+	} `bson:"collation"`
+}
+
+// EnsureCollection create collection of model with options supplied by entity.CollectionOptionsSupplier if it does not exist,
+// options of existing collection are compared with declared ones and mismatches are reported
+func EnsureCollection[MODEL any](ctx context.Context, db *Database) (*CollectionReport, error) {
+	schema, err := entity.GetOrParse(new(MODEL))
+	if err != nil {
+		return nil, err
+	}
+
+	return db.ensureCollection(ctx, schema)
+}
+
+// EnsureAll ensure collections created by NewCollection with any Database of the same client and name,
+// sorted by collection name
+func (th *Database) EnsureAll(ctx context.Context) ([]*CollectionReport, error) {
+	var reports []*CollectionReport
+	for _, schema := range th.schemas() {
+		report, err := th.ensureCollection(ctx, schema)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (th *Database) ensureCollection(ctx context.Context, schema *entity.Entity) (*CollectionReport, error) {
+	report := &CollectionReport{Collection: schema.Collection}
+
+	opt := schema.CollectionOptions
+	if opt == nil {
+		opt = options.CreateCollection()
+	}
+
+	specs, err := th.db.ListCollectionSpecifications(ctx, bson.M{"name": schema.Collection})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(specs) == 0 {
+		err = th.db.CreateCollection(ctx, schema.Collection, opt)
+		if err == nil {
+			report.Created = true
+			return report, nil
+		}
+
+		// created by others concurrently
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) || commandErr.Name != "NamespaceExists" {
+			return nil, errors.WithStack(err)
+		}
+
+		specs, err = th.db.ListCollectionSpecifications(ctx, bson.M{"name": schema.Collection})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if len(specs) == 0 {
+			return report, nil
+		}
+	}
+
+	var existing collectionSpecOptions
+	if len(specs[0].Options) > 0 {
+		if err = bson.Unmarshal(specs[0].Options, &existing); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	report.Mismatches = compareCollectionOptions(opt, existing)
+	return report, nil
+}
+
+// compareCollectionOptions only options declared are compared
+func compareCollectionOptions(declared *options.CreateCollectionOptions, existing collectionSpecOptions) []string {
+	var mismatches []string
+	mismatch := func(name string, want any, got any) {
+		mismatches = append(mismatches, fmt.Sprintf("%s: declared %v, existing %v", name, want, got))
+	}
+
+	if declared.Capped != nil && *declared.Capped != existing.Capped {
+		mismatch("capped", *declared.Capped, existing.Capped)
+	}
+	// size of capped collection may be rounded up to a multiple of 256
+	if declared.SizeInBytes != nil && (existing.Size == nil || *existing.Size < *declared.SizeInBytes || *existing.Size >= *declared.SizeInBytes+256) {
+		mismatch("size", *declared.SizeInBytes, valueOrNil(existing.Size))
+	}
+	if declared.MaxDocuments != nil && (existing.Max == nil || *existing.Max != *declared.MaxDocuments) {
+		mismatch("max", *declared.MaxDocuments, valueOrNil(existing.Max))
+	}
+	if declared.ExpireAfterSeconds != nil && (existing.ExpireAfterSeconds == nil || *existing.ExpireAfterSeconds != *declared.ExpireAfterSeconds) {
+		mismatch("expireAfterSeconds", *declared.ExpireAfterSeconds, valueOrNil(existing.ExpireAfterSeconds))
+	}
+
+	if ts := declared.TimeSeriesOptions; ts != nil {
+		if existing.TimeSeries == nil {
+			mismatch("timeseries", ts.TimeField, nil)
+		} else {
+			if ts.TimeField != existing.TimeSeries.TimeField {
+				mismatch("timeseries.timeField", ts.TimeField, existing.TimeSeries.TimeField)
+			}
+			if ts.MetaField != nil && *ts.MetaField != existing.TimeSeries.MetaField {
+				mismatch("timeseries.metaField", *ts.MetaField, existing.TimeSeries.MetaField)
+			}
+			if ts.Granularity != nil && *ts.Granularity != existing.TimeSeries.Granularity {
+				mismatch("timeseries.granularity", *ts.Granularity, existing.TimeSeries.Granularity)
+			}
+		}
+	}
+
+	if declared.ClusteredIndex != nil && existing.ClusteredIndex == nil {
+		mismatch("clusteredIndex", "clustered", nil)
+	}
+
+	if collation := declared.Collation; collation != nil {
+		if existing.Collation == nil {
+			mismatch("collation", collation.Locale, nil)
+		} else {
+			if collation.Locale != existing.Collation.Locale {
+				mismatch("collation.locale", collation.Locale, existing.Collation.Locale)
+			}
+			if collation.Strength != 0 && collation.Strength != existing.Collation.Strength {
+				mismatch("collation.strength", collation.Strength, existing.Collation.Strength)
+			}
+		}
+	}
+
+	return mismatches
+}
+
+func valueOrNil(v *int64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
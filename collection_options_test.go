@@ -0,0 +1,52 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type Metric struct {
+	Id        SObjectId `bson:"_id"`
+	Timestamp time.Time `bson:"timestamp"`
+	Sensor    string    `bson:"sensor"`
+}
+
+func (th *Metric) CollectionOptions() *options.CreateCollectionOptions {
+	return options.CreateCollection().
+		SetTimeSeriesOptions(options.TimeSeries().SetTimeField("timestamp").SetMetaField("sensor")).
+		SetExpireAfterSeconds(3600)
+}
+
+func Test_CollectionOptions(t *testing.T) {
+	schema, err := entity.GetOrParse(&Metric{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if schema.CollectionOptions == nil || schema.CollectionOptions.TimeSeriesOptions.TimeField != "timestamp" {
+		t.Fatal("collection options should be supplied by model")
+	}
+
+	var existing collectionSpecOptions
+	if mismatches := compareCollectionOptions(schema.CollectionOptions, existing); len(mismatches) != 2 {
+		t.Fatalf("unexpected mismatches %v", mismatches)
+	}
+
+	expire := int64(3600)
+	existing.ExpireAfterSeconds = &expire
+	existing.TimeSeries = &struct {
+		TimeField   string `bson:"timeField"`
+		MetaField   string `bson:"metaField"`
+		Granularity string `bson:"granularity"`
+	}{TimeField: "timestamp", MetaField: "sensor", Granularity: "seconds"}
+	if mismatches := compareCollectionOptions(schema.CollectionOptions, existing); len(mismatches) != 0 {
+		t.Fatalf("unexpected mismatches %v", mismatches)
+	}
+
+	size := int64(1024)
+	capped := options.CreateCollection().SetCapped(true).SetSizeInBytes(1000)
+	if mismatches := compareCollectionOptions(capped, collectionSpecOptions{Capped: true, Size: &size}); len(mismatches) != 0 {
+		t.Fatalf("rounded size should match, got %v", mismatches)
+	}
+}
@@ -287,7 +287,9 @@ func setupMongoClient(mongoUrl string) *Client {
 		},
 	})
 
-	client, err := NewClient(options.Client().ApplyURI(mongoUrl), monitorOptions)
+	client, err := NewClient(ClientConfig{
+		Opts: []*options.ClientOptions{options.Client().ApplyURI(mongoUrl), monitorOptions},
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -3,9 +3,11 @@ package jmgo
 import (
 	"context"
 	"fmt"
+	"github.com/wsk-go/jmgo/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
 	"sync"
 	"time"
 )
@@ -15,6 +17,18 @@ type Database struct {
 	client          *Client
 	lastResumeToken bson.Raw
 	cache           sync.Map
+	// registered migrations
+	migrations     []Migration
+	migrationMutex sync.Mutex
+}
+
+// schemaRegistry schemas of collections created by NewCollection, keyed by databaseKey,
+// so that they are found by every Database of the same database, e.g. those returned by Client.Database
+var schemaRegistry sync.Map
+
+type databaseKey struct {
+	client *mongo.Client
+	name   string
 }
 
 func NewDatabase(db *mongo.Database, client *Client) *Database {
@@ -26,6 +40,8 @@ func (th *Database) Database() *mongo.Database {
 }
 
 // Watch listen: 出错直接使用panic
+//
+// Deprecated: Watch can not be stopped and panics on error, use Subscribe instead
 func (th *Database) Watch(opts *options.ChangeStreamOptions, matchStage bson.D, listen func(stream *mongo.ChangeStream) error) {
 
 	for {
@@ -71,3 +87,27 @@ func (th *Database) Watch(opts *options.ChangeStreamOptions, matchStage bson.D,
 		}()
 	}
 }
+
+// registerSchema register schema of collection created by NewCollection
+func (th *Database) registerSchema(schema *entity.Entity) {
+	schemas, _ := schemaRegistry.LoadOrStore(databaseKey{client: th.db.Client(), name: th.db.Name()}, &sync.Map{})
+	schemas.(*sync.Map).Store(schema.Collection, schema)
+}
+
+// schemas of collections created by NewCollection with any Database of the same client and name, sorted by collection name
+func (th *Database) schemas() []*entity.Entity {
+	var schemas []*entity.Entity
+	registered, ok := schemaRegistry.Load(databaseKey{client: th.db.Client(), name: th.db.Name()})
+	if !ok {
+		return schemas
+	}
+
+	registered.(*sync.Map).Range(func(key, value any) bool {
+		schemas = append(schemas, value.(*entity.Entity))
+		return true
+	})
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Collection < schemas[j].Collection
+	})
+	return schemas
+}
@@ -0,0 +1,55 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+)
+
+// Distinct distinct values of field, fieldName is name defined in model,
+// values are decoded by bson codec of T, so custom types such as SObjectId and MilliTime are supported
+func Distinct[T any, MODEL any, ID any](ctx context.Context, col *Collection[MODEL, ID], fieldName string, filter any, opts ...*options.DistinctOptions) ([]T, error) {
+
+	field, err := col.mustSchemaField(fieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+	query, _, err := col.convertFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := col.collection.Distinct(ctx, field.DBName, query, opts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return convertDistinctValues[T](values)
+}
+
+// convertDistinctValues null is converted to zero value of T
+func convertDistinctValues[T any](values []any) ([]T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	out := make([]T, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			var zero T
+			out = append(out, zero)
+			continue
+		}
+
+		converted, err := convertValue(value, t)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, converted.Interface().(T))
+	}
+
+	return out, nil
+}
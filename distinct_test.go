@@ -0,0 +1,40 @@
+package jmgo
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func Test_ConvertDistinctValues(t *testing.T) {
+	oid := primitive.NewObjectID()
+	ids, err := convertDistinctValues[SObjectId]([]any{oid, "abc", nil})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(ids) != 3 || ids[0] != SObjectId(oid.Hex()) || ids[1] != "abc" || ids[2] != "" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+
+	// int32 and int64 returned by server are converted to int
+	ages, err := convertDistinctValues[int]([]any{int32(1), int64(2)})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(ages) != 2 || ages[0] != 1 || ages[1] != 2 {
+		t.Fatalf("unexpected ages %v", ages)
+	}
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	times, err := convertDistinctValues[MilliTime]([]any{primitive.NewDateTimeFromTime(now)})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(times) != 1 || !time.Time(times[0]).Equal(now) {
+		t.Fatalf("unexpected times %v", times)
+	}
+
+	if _, err = convertDistinctValues[int]([]any{"abc"}); err == nil {
+		t.Fatal("expect error for value can not be converted")
+	}
+}
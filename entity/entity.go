@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wsk-go/jmgo/errortype"
 	"github.com/wsk-go/jmgo/internal/utils"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"reflect"
 	"sync"
 )
@@ -16,8 +17,22 @@ type Entity struct {
 	ModelType  reflect.Type
 	Collection string
 	IdField    *EntityField
-	DBNames    []string
-	Fields     []*EntityField
+	// SoftDeleteField field with tag jmgo:"softDelete", documents are marked as deleted by it instead of being deleted
+	SoftDeleteField *EntityField
+	// VersionField integer field with tag jmgo:"version" used for optimistic locking
+	VersionField *EntityField
+	// CreatedAtField time or integer(unix milli) field with tag jmgo:"createdAt" filled on insert
+	CreatedAtField *EntityField
+	// UpdatedAtField time or integer(unix milli) field with tag jmgo:"updatedAt" filled on insert and update
+	UpdatedAtField *EntityField
+	// Relations references to other models by name of companion field
+	Relations map[string]*Relation
+	// CollectionOptions options used to create collection, supplied by CollectionOptionsSupplier
+	CollectionOptions *options.CreateCollectionOptions
+	// Indexes declared by jmgo tag
+	Indexes []*Index
+	DBNames []string
+	Fields  []*EntityField
 	//Fields      []*EntityField
 	FieldsByName   map[string]*EntityField
 	FieldsByDBName map[string]*EntityField
@@ -63,6 +78,11 @@ func newEntityByModelType(modelType reflect.Type, index []int) (*Entity, error)
 		collectionName = utils.LowerFirst(modelType.Name())
 	}
 
+	var collectionOptions *options.CreateCollectionOptions
+	if supplier, ok := modelValue.Interface().(CollectionOptionsSupplier); ok {
+		collectionOptions = supplier.CollectionOptions()
+	}
+
 	entity := &Entity{}
 
 	// extract fields from model type
@@ -77,6 +97,40 @@ func newEntityByModelType(modelType reflect.Type, index []int) (*Entity, error)
 		return nil, errors.WithStack(errortype.ErrIdFieldDoesNotExists)
 	}
 
+	// extract soft delete field from fields
+	softDeleteField, err := extractSoftDeleteField(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// extract version field from fields
+	versionField, err := extractVersionField(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// extract timestamp fields from fields
+	createdAtField, err := extractTimestampField(fields, SettingCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	updatedAtField, err := extractTimestampField(fields, SettingUpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// extract relations
+	relations, err := extractRelations(modelType, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// extract indexes
+	indexes, err := extractIndexes(fields)
+	if err != nil {
+		return nil, err
+	}
+
 	// create map for fields by name and by db name
 	fieldsByName, fieldsByDBName := makeFieldsByNameAndByDBName(fields)
 
@@ -85,9 +139,16 @@ func newEntityByModelType(modelType reflect.Type, index []int) (*Entity, error)
 	entity.ModelType = modelType
 	entity.Fields = fields
 	entity.Collection = collectionName
+	entity.CollectionOptions = collectionOptions
 	entity.FieldsByName = fieldsByName
 	entity.FieldsByDBName = fieldsByDBName
 	entity.IdField = idField
+	entity.SoftDeleteField = softDeleteField
+	entity.VersionField = versionField
+	entity.CreatedAtField = createdAtField
+	entity.UpdatedAtField = updatedAtField
+	entity.Relations = relations
+	entity.Indexes = indexes
 
 	return entity, nil
 }
@@ -151,6 +212,54 @@ func extractIdField(fields []*EntityField) *EntityField {
 	return idField
 }
 
+// soft delete field must be bool, integer(unix milli) or time
+func extractSoftDeleteField(fields []*EntityField) (*EntityField, error) {
+	for _, field := range fields {
+		if !field.HasSetting(SettingSoftDelete) {
+			continue
+		}
+
+		if !IsBoolType(field.FieldType) && !IsIntegerType(field.FieldType) && !IsTimeType(field.FieldType) {
+			return nil, errors.WithStack(fmt.Errorf("%w: soft delete field %s must be bool, integer or time", errortype.ErrUnsupportedDataType, field.Name))
+		}
+		return field, nil
+	}
+
+	return nil, nil
+}
+
+// version field must be integer
+func extractVersionField(fields []*EntityField) (*EntityField, error) {
+	for _, field := range fields {
+		if !field.HasSetting(SettingVersion) {
+			continue
+		}
+
+		if !IsIntegerType(field.FieldType) {
+			return nil, errors.WithStack(fmt.Errorf("%w: version field %s must be integer", errortype.ErrUnsupportedDataType, field.Name))
+		}
+		return field, nil
+	}
+
+	return nil, nil
+}
+
+// timestamp field must be integer or time
+func extractTimestampField(fields []*EntityField, setting string) (*EntityField, error) {
+	for _, field := range fields {
+		if !field.HasSetting(setting) {
+			continue
+		}
+
+		if !IsIntegerType(field.FieldType) && !IsTimeType(field.FieldType) {
+			return nil, errors.WithStack(fmt.Errorf("%w: %s field %s must be integer or time", errortype.ErrUnsupportedDataType, setting, field.Name))
+		}
+		return field, nil
+	}
+
+	return nil, nil
+}
+
 func makeFieldsByNameAndByDBName(fields []*EntityField) (fieldsByName, fieldsByDBName map[string]*EntityField) {
 	fieldsByName = map[string]*EntityField{}
 	fieldsByDBName = map[string]*EntityField{}
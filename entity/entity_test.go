@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type Order struct {
@@ -99,3 +100,29 @@ func Benchmark(b *testing.B) {
 	//    }
 	//})
 }
+
+func Test_SoftDeleteField(t *testing.T) {
+	type Account struct {
+		Id        string     `bson:"_id"`
+		DeletedAt *time.Time `bson:"deletedAt" jmgo:"softDelete"`
+	}
+
+	e, err := GetOrParse(&Account{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if e.SoftDeleteField == nil || e.SoftDeleteField.DBName != "deletedAt" {
+		t.Fatalf("unexpected soft delete field %v", e.SoftDeleteField)
+	}
+
+	type InvalidAccount struct {
+		Id      string `bson:"_id"`
+		Deleted string `bson:"deleted" jmgo:"softDelete"`
+	}
+
+	_, err = GetOrParse(&InvalidAccount{})
+	if err == nil {
+		t.Fatal("expect error for string soft delete field")
+	}
+}
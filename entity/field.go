@@ -11,6 +11,8 @@ type EntityField struct {
 	FieldType   reflect.Type
 	StructField reflect.StructField
 	StructTags  StructTags
+	// Settings parsed from jmgo tag
+	Settings map[string]string
 	//Entity               *Entity
 	index       int
 	inlineIndex []int
@@ -37,6 +39,7 @@ func newField(structField reflect.StructField, structTags StructTags, inlineInde
 		Name:           structField.Name,
 		DBName:         structTags.Name,
 		StructTags:     structTags,
+		Settings:       parseSettings(structField.Tag.Get("jmgo")),
 		Id:             structTags.Name == "_id",
 		FieldType:      structField.Type,
 		StructField:    structField,
@@ -48,6 +51,12 @@ func newField(structField reflect.StructField, structTags StructTags, inlineInde
 	return field, nil
 }
 
+// HasSetting whether the key is set in jmgo tag
+func (th *EntityField) HasSetting(key string) bool {
+	_, ok := th.Settings[key]
+	return ok
+}
+
 type ValueOfFunc func(value reflect.Value) (any, bool)
 type ReflectOfFunc func(value reflect.Value) reflect.Value
 
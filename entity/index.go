@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"strconv"
+	"time"
+)
+
+// Index declared by jmgo tag, fields with same index name make a compound index in order of declaration
+//
+//	Email    string    `bson:"email" jmgo:"unique,partial"`
+//	UserId   SObjectId `bson:"userId" jmgo:"index=idx_user_time"`
+//	CreateAt MilliTime `bson:"createdAt" jmgo:"index=idx_user_time,order=-1"`
+//	ExpireAt time.Time `bson:"expireAt" jmgo:"expireAfter=24h"`
+type Index struct {
+	Name string
+	Keys []IndexKey
+	// Unique set if any field of index is declared with unique
+	Unique bool
+	// Sparse set if any field of index is declared with sparse
+	Sparse bool
+	// ExpireAfter documents are removed after the duration since time of field, zero means never
+	ExpireAfter time.Duration
+	// PartialFields only documents with these fields are indexed
+	PartialFields []string
+}
+
+type IndexKey struct {
+	DBName string
+	// Order 1 or -1
+	Order int
+}
+
+// extractIndexes index without name is named as mongodb does, e.g. "email_1"
+func extractIndexes(fields []*EntityField) ([]*Index, error) {
+	var indexes []*Index
+	indexesByName := map[string]*Index{}
+
+	for _, field := range fields {
+		indexName, hasIndex := field.Settings[SettingIndex]
+		uniqueName, hasUnique := field.Settings[SettingUnique]
+		expireAfter, hasExpireAfter := field.Settings[SettingExpireAfter]
+		if !hasIndex && !hasUnique && !hasExpireAfter {
+			continue
+		}
+
+		if indexName != "" && uniqueName != "" && indexName != uniqueName {
+			return nil, errors.WithStack(fmt.Errorf("%w: field %s declares different index names %s and %s", errortype.ErrInvalidIndex, field.Name, indexName, uniqueName))
+		}
+		name := indexName
+		if name == "" {
+			name = uniqueName
+		}
+
+		order := 1
+		if value, ok := field.Settings[SettingOrder]; ok {
+			v, err := strconv.Atoi(value)
+			if err != nil || (v != 1 && v != -1) {
+				return nil, errors.WithStack(fmt.Errorf("%w: order of field %s must be 1 or -1", errortype.ErrInvalidIndex, field.Name))
+			}
+			order = v
+		}
+
+		if name == "" {
+			name = fmt.Sprintf("%s_%d", field.DBName, order)
+		}
+
+		index, ok := indexesByName[name]
+		if !ok {
+			index = &Index{Name: name}
+			indexesByName[name] = index
+			indexes = append(indexes, index)
+		}
+
+		index.Keys = append(index.Keys, IndexKey{DBName: field.DBName, Order: order})
+		index.Unique = index.Unique || hasUnique
+		index.Sparse = index.Sparse || field.HasSetting(SettingSparse)
+		if field.HasSetting(SettingPartial) {
+			index.PartialFields = append(index.PartialFields, field.DBName)
+		}
+
+		if hasExpireAfter {
+			d, err := time.ParseDuration(expireAfter)
+			// ttl index is declared in seconds, fraction would be truncated
+			if err != nil || d < time.Second || d%time.Second != 0 {
+				return nil, errors.WithStack(fmt.Errorf("%w: expireAfter of field %s must be positive whole seconds", errortype.ErrInvalidIndex, field.Name))
+			}
+			index.ExpireAfter = d
+		}
+	}
+
+	for _, index := range indexes {
+		if index.ExpireAfter > 0 && len(index.Keys) > 1 {
+			return nil, errors.WithStack(fmt.Errorf("%w: ttl index %s must be single field", errortype.ErrInvalidIndex, index.Name))
+		}
+	}
+
+	return indexes, nil
+}
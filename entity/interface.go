@@ -1,5 +1,12 @@
 package entity
 
+import "go.mongodb.org/mongo-driver/mongo/options"
+
 type CollectionNameSupplier interface {
     CollectionName() string
 }
+
+// CollectionOptionsSupplier options used to create collection of model, such as capped, time-series, clustered and collation
+type CollectionOptionsSupplier interface {
+    CollectionOptions() *options.CreateCollectionOptions
+}
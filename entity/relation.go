@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"reflect"
+)
+
+// Relation reference to another model declared by jmgo tag, the referenced models are filled into companion field
+//
+//	type Order struct {
+//		UserId SObjectId `bson:"userId" jmgo:"ref=User"`
+//		User   *User     `bson:"-"`
+//	}
+//
+//	type User struct {
+//		Id     SObjectId `bson:"_id"`
+//		Orders []*Order  `bson:"-" jmgo:"refBy=UserId,onDelete=cascade"`
+//	}
+type Relation struct {
+	// Name name of companion field
+	Name string
+	// Field field holding id of referenced model, it is nil for back reference
+	Field *EntityField
+	// ForeignField name of field in referenced model holding id of this model, it is only set for back reference
+	ForeignField string
+	// TargetType struct type of referenced model
+	TargetType reflect.Type
+	// Index index of companion field
+	Index []int
+	// Many companion field is slice
+	Many bool
+	// OnDelete rule applied to referencing documents when documents are deleted, it is only set for back reference
+	OnDelete string
+}
+
+// IsBackReference referenced models hold id of this model
+func (th *Relation) IsBackReference() bool {
+	return th.ForeignField != ""
+}
+
+// extractRelations companion fields are looked up by name, entity of referenced model is not parsed here
+// to avoid cyclic parsing
+func extractRelations(modelType reflect.Type, fields []*EntityField) (map[string]*Relation, error) {
+	relations := map[string]*Relation{}
+
+	// reference by field holding id
+	for _, field := range fields {
+		name, ok := field.Settings[SettingRef]
+		if !ok {
+			continue
+		}
+
+		companion, ok := modelType.FieldByName(name)
+		if !ok {
+			return nil, errors.WithStack(fmt.Errorf("%w: companion field %s of %s not found in %s", errortype.ErrUnsupportedDataType, name, field.Name, modelType.Name()))
+		}
+
+		relation, err := newRelation(modelType, companion)
+		if err != nil {
+			return nil, err
+		}
+		relation.Field = field
+		relations[name] = relation
+	}
+
+	// back reference declared on companion field
+	for _, structField := range reflect.VisibleFields(modelType) {
+		settings := parseSettings(structField.Tag.Get("jmgo"))
+		foreignField, ok := settings[SettingRefBy]
+		if !ok {
+			continue
+		}
+
+		relation, err := newRelation(modelType, structField)
+		if err != nil {
+			return nil, err
+		}
+		if !relation.Many {
+			return nil, errors.WithStack(fmt.Errorf("%w: back reference field %s of %s must be slice", errortype.ErrUnsupportedDataType, structField.Name, modelType.Name()))
+		}
+		relation.ForeignField = foreignField
+
+		onDelete := settings[SettingOnDelete]
+		switch onDelete {
+		case "", OnDeleteCascade, OnDeleteRestrict, OnDeleteSetNull:
+			relation.OnDelete = onDelete
+		default:
+			return nil, errors.WithStack(fmt.Errorf("%w: onDelete of %s must be cascade, restrict or setNull", errortype.ErrUnsupportedDataType, structField.Name))
+		}
+
+		relations[structField.Name] = relation
+	}
+
+	return relations, nil
+}
+
+// companion field can be *T, T, []*T or []T
+func newRelation(modelType reflect.Type, companion reflect.StructField) (*Relation, error) {
+	targetType := companion.Type
+	many := false
+	if targetType.Kind() == reflect.Slice {
+		many = true
+		targetType = targetType.Elem()
+	}
+	targetType = Indirect(targetType)
+
+	if targetType.Kind() != reflect.Struct {
+		return nil, errors.WithStack(fmt.Errorf("%w: companion field %s of %s must be struct, pointer of struct or slice of them", errortype.ErrUnsupportedDataType, companion.Name, modelType.Name()))
+	}
+
+	return &Relation{
+		Name:       companion.Name,
+		TargetType: targetType,
+		Index:      companion.Index,
+		Many:       many,
+	}, nil
+}
@@ -39,3 +39,58 @@ func parseTags(key string, tag string) (StructTags, error) {
 	return st, nil
 }
 
+// parseSettings parse jmgo tag, settings are separated by comma and value is optional
+// e.g. jmgo:"softDelete", jmgo:"index=idx_user_time,order=-1"
+func parseSettings(tag string) map[string]string {
+	settings := map[string]string{}
+	for _, str := range strings.Split(tag, ",") {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			continue
+		}
+		if index := strings.Index(str, "="); index >= 0 {
+			settings[strings.TrimSpace(str[:index])] = strings.TrimSpace(str[index+1:])
+		} else {
+			settings[str] = ""
+		}
+	}
+	return settings
+}
+
+const (
+	// SettingSoftDelete jmgo:"softDelete"
+	SettingSoftDelete = "softDelete"
+	// SettingVersion jmgo:"version"
+	SettingVersion = "version"
+	// SettingCreatedAt jmgo:"createdAt"
+	SettingCreatedAt = "createdAt"
+	// SettingUpdatedAt jmgo:"updatedAt"
+	SettingUpdatedAt = "updatedAt"
+	// SettingRef jmgo:"ref=Order", name of companion field filled with referenced model
+	SettingRef = "ref"
+	// SettingRefBy jmgo:"refBy=UserId", name of field in referenced model holding id of this model
+	SettingRefBy = "refBy"
+	// SettingOnDelete jmgo:"refBy=UserId,onDelete=cascade", what to do with referencing documents when documents are deleted
+	SettingOnDelete = "onDelete"
+	// SettingIndex jmgo:"index" or jmgo:"index=name", fields with same name make a compound index
+	SettingIndex = "index"
+	// SettingUnique jmgo:"unique" or jmgo:"unique=name"
+	SettingUnique = "unique"
+	// SettingOrder jmgo:"index,order=-1", order of field in index, default is 1
+	SettingOrder = "order"
+	// SettingExpireAfter jmgo:"expireAfter=24h", ttl index
+	SettingExpireAfter = "expireAfter"
+	// SettingSparse jmgo:"index,sparse"
+	SettingSparse = "sparse"
+	// SettingPartial jmgo:"unique,partial", only documents with the field are indexed
+	SettingPartial = "partial"
+)
+
+const (
+	// OnDeleteCascade referencing documents are deleted too
+	OnDeleteCascade = "cascade"
+	// OnDeleteRestrict deletion fails if any referencing document exists
+	OnDeleteRestrict = "restrict"
+	// OnDeleteSetNull reference of referencing documents is set to null, or pulled if it is a slice
+	OnDeleteSetNull = "setNull"
+)
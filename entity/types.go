@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Indirect element type if it is a pointer
+func Indirect(fieldType reflect.Type) reflect.Type {
+	if fieldType.Kind() == reflect.Ptr {
+		return fieldType.Elem()
+	}
+	return fieldType
+}
+
+func IsBoolType(fieldType reflect.Type) bool {
+	return Indirect(fieldType).Kind() == reflect.Bool
+}
+
+func IsIntegerType(fieldType reflect.Type) bool {
+	switch Indirect(fieldType).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// IsTimeType time.Time or type defined by it, such as MilliTime
+func IsTimeType(fieldType reflect.Type) bool {
+	fieldType = Indirect(fieldType)
+	return fieldType.Kind() == reflect.Struct && timeType.ConvertibleTo(fieldType)
+}
@@ -1,6 +1,9 @@
 package errortype
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrUnsupportedDataType = errors.New("unsupported data type")
@@ -10,4 +13,49 @@ var (
 	ErrIdFieldDoesNotExists = errors.New("id field does not exits, please add tag bson:\"_id\" on any field you want")
 
 	ErrModelTypeNotMatchInCollection = errors.New("model type not match in operator")
+
+	ErrInvalidPageToken = errors.New("invalid page token")
+
+	ErrInvalidPageLength = errors.New("page length must be positive")
+
+	ErrUpdateValueTypeNotMatch = errors.New("update value type not match field type")
+
+	ErrVersionConflict = errors.New("version conflict, document has been modified by others or does not exist")
+
+	ErrZeroCreatedAt = errors.New("createdAt of replacement is zero, it would overwrite createdAt of the replaced document")
+
+	ErrSoftDeleteFieldDoesNotExists = errors.New("soft delete field does not exits, please add tag jmgo:\"softDelete\" on the field")
+
+	ErrRelationDoesNotExists = errors.New("relation does not exits, please add tag jmgo:\"ref=Name\" or jmgo:\"refBy=Field\" and companion field")
+
+	ErrMigrationLocked = errors.New("migration is locked by another runner")
+
+	ErrMigrationLockLost = errors.New("migration lock is lost, it has expired and may be taken by another runner")
+
+	ErrMigrationIrreversible = errors.New("migration can not be rolled back, Down is not specified")
+
+	ErrInvalidMigration = errors.New("invalid migration")
+
+	ErrInvalidIndex = errors.New("invalid index declared by jmgo tag")
 )
+
+// ErrDeleteRestricted deletion is restricted by documents referencing the deleted documents
+var ErrDeleteRestricted = errors.New("delete restricted by referencing documents")
+
+// RestrictedError returned when rule onDelete=restrict is violated, errors.Is(err, ErrDeleteRestricted) is true
+type RestrictedError struct {
+	// Model name of deleted model
+	Model string
+	// Relation name of back reference field
+	Relation string
+	// Count number of referencing documents
+	Count int64
+}
+
+func (e *RestrictedError) Error() string {
+	return fmt.Sprintf("%s: %d documents of relation %s.%s", ErrDeleteRestricted.Error(), e.Count, e.Model, e.Relation)
+}
+
+func (e *RestrictedError) Unwrap() error {
+	return ErrDeleteRestricted
+}
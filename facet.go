@@ -0,0 +1,79 @@
+package jmgo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetFacetTotal use FindWithTotalByFacet as default when total is required
+func (th *Collection[MODEL, ID]) SetFacetTotal(facet bool) *Collection[MODEL, ID] {
+	th.facetTotal = facet
+	return th
+}
+
+type facetResult[MODEL any] struct {
+	Items []MODEL `bson:"items"`
+	Total []struct {
+		Count int64 `bson:"count"`
+	} `bson:"total"`
+}
+
+// FindWithTotalByFacet get page and total in a single round-trip using $facet,
+// sort, skip, limit and projection in options are applied to items.
+// note that items and total are returned in one document which can not exceed 16MB
+func (th *Collection[MODEL, ID]) FindWithTotalByFacet(ctx context.Context, filter any, opts ...*options.FindOptions) ([]MODEL, int64, error) {
+
+	convertedFilter, _, err := th.convertFilter(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pipeline := makeFacetPipeline(convertedFilter, options.MergeFindOptions(opts...))
+
+	var results []facetResult[MODEL]
+	err = th.Aggregate(ctx, pipeline, &results)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(results) == 0 {
+		return nil, 0, nil
+	}
+
+	var total int64
+	if len(results[0].Total) > 0 {
+		total = results[0].Total[0].Count
+	}
+
+	return results[0].Items, total, nil
+}
+
+// makeFacetPipeline items are sorted, paged and projected by options, total counts all matched documents
+func makeFacetPipeline(query any, opt *options.FindOptions) bson.A {
+	items := bson.A{}
+	if opt.Sort != nil {
+		items = append(items, bson.M{"$sort": opt.Sort})
+	}
+	if opt.Skip != nil && *opt.Skip > 0 {
+		items = append(items, bson.M{"$skip": *opt.Skip})
+	}
+	if opt.Limit != nil && *opt.Limit > 0 {
+		items = append(items, bson.M{"$limit": *opt.Limit})
+	}
+	if opt.Projection != nil {
+		items = append(items, bson.M{"$project": opt.Projection})
+	}
+	// sub-pipeline of $facet can not be empty
+	if len(items) == 0 {
+		items = append(items, bson.M{"$skip": 0})
+	}
+
+	return bson.A{
+		bson.M{"$match": query},
+		bson.M{"$facet": bson.M{
+			"items": items,
+			"total": bson.A{bson.M{"$count": "count"}},
+		}},
+	}
+}
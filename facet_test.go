@@ -0,0 +1,37 @@
+package jmgo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"testing"
+)
+
+func Test_MakeFacetPipeline(t *testing.T) {
+	query := bson.M{"name": "abc"}
+	opt := options.Find().SetSort(bson.D{{Key: "happy", Value: -1}}).SetSkip(20).SetLimit(10).SetProjection(bson.D{{Key: "name", Value: 1}})
+
+	pipeline := makeFacetPipeline(query, opt)
+	expected := bson.A{
+		bson.M{"$match": query},
+		bson.M{"$facet": bson.M{
+			"items": bson.A{
+				bson.M{"$sort": bson.D{{Key: "happy", Value: -1}}},
+				bson.M{"$skip": int64(20)},
+				bson.M{"$limit": int64(10)},
+				bson.M{"$project": bson.D{{Key: "name", Value: 1}}},
+			},
+			"total": bson.A{bson.M{"$count": "count"}},
+		}},
+	}
+	if !reflect.DeepEqual(pipeline, expected) {
+		t.Fatalf("unexpected pipeline %v", pipeline)
+	}
+
+	// sub-pipeline of items can not be empty, zero skip and limit are ignored
+	pipeline = makeFacetPipeline(query, options.Find().SetSkip(0).SetLimit(0))
+	items := pipeline[1].(bson.M)["$facet"].(bson.M)["items"]
+	if !reflect.DeepEqual(items, bson.A{bson.M{"$skip": 0}}) {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
@@ -0,0 +1,276 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+)
+
+// FindOneAndUpdate update can be MODEL whose non-zero fields will be set or *UpdateBuilder, fields set are validated after BeforeUpdate,
+// return the document before update or after update if returnAfter is true, found is false if no document matches.
+// version of MODEL is required to match, or ErrVersionConflict is returned
+func (th *Collection[MODEL, ID]) FindOneAndUpdate(ctx context.Context, filter any, update any, returnAfter bool, opts ...*options.FindOneAndUpdateOptions) (MODEL, bool, error) {
+
+	var out MODEL
+
+	query, err := th.mustConvertFilter(filter)
+	if err != nil {
+		return out, false, err
+	}
+
+	err = th.tryCallBeforeUpdateHook(update)
+	if err != nil {
+		return out, false, err
+	}
+
+	doc, err := th.mapToUpdate(update, nil)
+	if err != nil {
+		return out, false, err
+	}
+
+	// only fields written are validated, fields of *UpdateBuilder are validated when it is built
+	if model, ok := update.(MODEL); ok {
+		set, _ := doc["$set"].(bson.M)
+		if err = th.validateFields(model, set); err != nil {
+			return out, false, err
+		}
+	}
+	th.applyTimestampsToUpdate(doc, update)
+
+	// optimistic locking
+	versioned := th.isVersioned(update)
+	if versioned {
+		th.increaseVersionInUpdate(doc)
+		query = th.addVersionCondition(query, update)
+	}
+
+	opts = append(opts, options.FindOneAndUpdate().SetReturnDocument(th.returnDocument(returnAfter)))
+	out, found, err := th.decodeSingleResult(th.collection.FindOneAndUpdate(ctx, query, doc, opts...))
+	if err != nil {
+		return out, false, err
+	}
+
+	if versioned {
+		if !found {
+			return out, false, errors.WithStack(errortype.ErrVersionConflict)
+		}
+		th.increaseModelVersion(update)
+	}
+
+	th.tryCallAfterUpdateHook(update)
+
+	return out, found, nil
+}
+
+// FindOneAndDelete return the deleted document, found is false if no document matches.
+// document is marked as deleted if model has soft delete field, rules declared by jmgo:"onDelete" are applied before it is deleted
+func (th *Collection[MODEL, ID]) FindOneAndDelete(ctx context.Context, filter any, opts ...*options.FindOneAndDeleteOptions) (MODEL, bool, error) {
+
+	var out MODEL
+
+	query, err := th.mustConvertFilter(filter)
+	if err != nil {
+		return out, false, err
+	}
+
+	if len(deleteRulesOf(th.schema)) == 0 {
+		return th.findOneAndDelete(ctx, query, opts...)
+	}
+
+	found := false
+	err = th.withDeleteTransaction(ctx, func(ctx context.Context) error {
+		// the document is found by sort of options first, so that rules are applied on the deleted one
+		deleteOpt := options.MergeFindOneAndDeleteOptions(opts...)
+		findOpt := options.FindOne().SetProjection(bson.M{th.schema.IdDBName(): 1})
+		if deleteOpt.Sort != nil {
+			findOpt.SetSort(deleteOpt.Sort)
+		}
+		if deleteOpt.Collation != nil {
+			findOpt.SetCollation(deleteOpt.Collation)
+		}
+
+		var doc struct {
+			Id any `bson:"_id"`
+		}
+		if err := th.collection.FindOne(ctx, query, findOpt).Decode(&doc); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+
+		db := th.collection.Database()
+		steps, err := planDeleteRules(ctx, db, th.schema, bson.A{doc.Id}, map[string]bool{})
+		if err != nil {
+			return err
+		}
+		if err = runDeleteSteps(ctx, db, steps, th.now()); err != nil {
+			return err
+		}
+
+		out, found, err = th.findOneAndDelete(ctx, bson.M{th.schema.IdDBName(): doc.Id}, opts...)
+		return err
+	})
+	if err != nil {
+		return out, false, err
+	}
+
+	return out, found, nil
+}
+
+func (th *Collection[MODEL, ID]) findOneAndDelete(ctx context.Context, query any, opts ...*options.FindOneAndDeleteOptions) (MODEL, bool, error) {
+	if th.schema.SoftDeleteField != nil {
+		deleteOpt := options.MergeFindOneAndDeleteOptions(opts...)
+		updateOpt := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+		if deleteOpt.Projection != nil {
+			updateOpt.SetProjection(deleteOpt.Projection)
+		}
+		if deleteOpt.Sort != nil {
+			updateOpt.SetSort(deleteOpt.Sort)
+		}
+		return th.decodeSingleResult(th.collection.FindOneAndUpdate(ctx, query, th.makeDeletedUpdate(), updateOpt))
+	}
+
+	return th.decodeSingleResult(th.collection.FindOneAndDelete(ctx, query, opts...))
+}
+
+// FindOneAndReplace replace the whole document except id, createdAt of model must be non-zero,
+// return the document before replacement or after replacement if returnAfter is true, found is false if no document matches.
+// version of model is required to match, or ErrVersionConflict is returned
+func (th *Collection[MODEL, ID]) FindOneAndReplace(ctx context.Context, filter any, model MODEL, returnAfter bool, opts ...*options.FindOneAndReplaceOptions) (MODEL, bool, error) {
+
+	var out MODEL
+
+	query, err := th.mustConvertFilter(filter)
+	if err != nil {
+		return out, false, err
+	}
+
+	replacement, err := th.mapToReplacement(model)
+	if err != nil {
+		return out, false, err
+	}
+
+	// optimistic locking
+	versioned := th.isVersioned(model)
+	if versioned {
+		query = th.addVersionCondition(query, model)
+		replacement = th.increaseVersionInReplacement(replacement, model)
+	}
+
+	opts = append(opts, options.FindOneAndReplace().SetReturnDocument(th.returnDocument(returnAfter)))
+	out, found, err := th.decodeSingleResult(th.collection.FindOneAndReplace(ctx, query, replacement, opts...))
+	if err != nil {
+		return out, false, err
+	}
+
+	if versioned {
+		if !found {
+			return out, false, errors.WithStack(errortype.ErrVersionConflict)
+		}
+		th.increaseModelVersion(model)
+	}
+
+	th.tryCallAfterUpdateHook(model)
+
+	return out, found, nil
+}
+
+// validateFields validate fields of partial update by validate tag of each field, fields not in set are not validated.
+// custom ValidateFunc of client is not called, because it validates the whole model
+func (th *Collection[MODEL, ID]) validateFields(model MODEL, set bson.M) error {
+	if th.client.Validate != nil {
+		return nil
+	}
+
+	value := reflect.ValueOf(model)
+	for _, field := range th.schema.Fields {
+		if _, ok := set[field.DBName]; !ok {
+			continue
+		}
+
+		tag := field.StructField.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		if err := validate.Var(field.ReflectValueOf(value).Interface(), tag); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (th *Collection[MODEL, ID]) returnDocument(returnAfter bool) options.ReturnDocument {
+	if returnAfter {
+		return options.After
+	}
+	return options.Before
+}
+
+func (th *Collection[MODEL, ID]) decodeSingleResult(result *mongo.SingleResult) (MODEL, bool, error) {
+	var out MODEL
+
+	err := result.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return out, false, nil
+		}
+		return out, false, err
+	}
+
+	err = result.Decode(&out)
+	if err != nil {
+		return out, false, err
+	}
+
+	return out, true, nil
+}
+
+// mapToReplacement call BeforeSave hook, validate the whole model and map it to document without id,
+// so that id of the replaced document is kept. createdAt of model must be non-zero, because the whole document
+// including createdAt is replaced, so load the document first or fill createdAt of it
+func (th *Collection[MODEL, ID]) mapToReplacement(model MODEL) (bson.D, error) {
+
+	th.stampOnUpdate(model, th.now())
+
+	if d, ok := any(model).(BeforeSave); ok {
+		if err := d.BeforeSave(); err != nil {
+			return nil, err
+		}
+	}
+
+	if field := th.schema.CreatedAtField; field != nil {
+		if _, zero := field.ValueOf(reflect.ValueOf(model)); zero {
+			return nil, errors.WithStack(errortype.ErrZeroCreatedAt)
+		}
+	}
+
+	if err := th.validate(model); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	data, err := bson.Marshal(model)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var doc bson.D
+	err = bson.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	replacement := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != th.schema.IdDBName() {
+			replacement = append(replacement, e)
+		}
+	}
+
+	return replacement, nil
+}
@@ -0,0 +1,120 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type ReplaceArticle struct {
+	Id        SObjectId `bson:"_id,omitempty"`
+	Name      string    `bson:"name"`
+	CreatedAt time.Time `bson:"createdAt" jmgo:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" jmgo:"updatedAt"`
+}
+
+func Test_MapToReplacement(t *testing.T) {
+	schema, err := entity.GetOrParse(&ReplaceArticle{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	col := &Collection[*ReplaceArticle, SObjectId]{schema: schema, client: &Client{Now: func() time.Time { return now }}}
+
+	createdAt := now.Add(-time.Hour)
+	replacement, err := col.mapToReplacement(&ReplaceArticle{Id: "1", Name: "abc", CreatedAt: createdAt})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	// id is removed, createdAt is kept and updatedAt is stamped
+	if len(replacement) != 3 || replacement[0] != (bson.E{Key: "name", Value: "abc"}) ||
+		replacement[1] != (bson.E{Key: "createdAt", Value: primitive.NewDateTimeFromTime(createdAt)}) ||
+		replacement[2] != (bson.E{Key: "updatedAt", Value: primitive.NewDateTimeFromTime(now)}) {
+		t.Fatalf("unexpected replacement %v", replacement)
+	}
+
+	// zero createdAt would overwrite the stored one
+	_, err = col.mapToReplacement(&ReplaceArticle{Id: "1", Name: "abc"})
+	if !errors.Is(err, errortype.ErrZeroCreatedAt) {
+		t.Fatalf("expected ErrZeroCreatedAt, got %v", err)
+	}
+}
+
+func Test_FindOneAndUpdateValidate(t *testing.T) {
+	type Article struct {
+		Id    SObjectId `bson:"_id,omitempty"`
+		Name  string    `bson:"name" validate:"max=3"`
+		Title string    `bson:"title" validate:"required"`
+	}
+
+	schema, err := entity.GetOrParse(&Article{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Article, SObjectId]{schema: schema, client: &Client{}}
+
+	// invalid model is rejected before it is sent to mongodb
+	_, _, err = col.FindOneAndUpdate(context.Background(), bson.M{"_id": "1"}, &Article{Name: "abcd"}, true)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	// fields not written by partial update are not validated
+	if err = col.validateFields(&Article{Name: "abc"}, bson.M{"name": "abc"}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err = col.validateFields(&Article{Name: "abc"}, bson.M{"name": "abc", "title": ""}); !errors.As(err, &validationErrors) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func Test_ReturnDocument(t *testing.T) {
+	col := &Collection[*Test, SObjectId]{}
+	if col.returnDocument(true) != options.After || col.returnDocument(false) != options.Before {
+		t.Fatal("unexpected return document")
+	}
+}
+
+type HookedArticle struct {
+	Id   SObjectId `bson:"_id,omitempty"`
+	Name string    `bson:"name" validate:"required"`
+	Slug string    `bson:"slug"`
+}
+
+func (th *HookedArticle) BeforeSave() error {
+	th.Slug = "slug-" + th.Name
+	return nil
+}
+
+func Test_ReplaceOneHooks(t *testing.T) {
+	schema, err := entity.GetOrParse(&HookedArticle{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*HookedArticle, SObjectId]{schema: schema, client: &Client{}}
+
+	// BeforeSave is called before the model is mapped
+	replacement, err := col.mapToReplacement(&HookedArticle{Id: "1", Name: "abc"})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(replacement) != 2 || replacement[1] != (bson.E{Key: "slug", Value: "slug-abc"}) {
+		t.Fatalf("unexpected replacement %v", replacement)
+	}
+
+	// invalid model is rejected before it is sent to mongodb
+	_, err = col.ReplaceOneById(context.Background(), "1", &HookedArticle{})
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+)
+
+// SetIdGenerator id is generated before insert if id field of model is zero
+func (th *Collection[MODEL, ID]) SetIdGenerator(generator IdGenerator) *Collection[MODEL, ID] {
+	th.idGenerator = generator
+	return th
+}
+
+// convertId convert id returned by mongodb, such as primitive.ObjectID, to ID
+func (th *Collection[MODEL, ID]) convertId(v any) (ID, error) {
+	var id ID
+	if v == nil {
+		return id, nil
+	}
+
+	if i, ok := v.(ID); ok {
+		return i, nil
+	}
+
+	value, err := convertValue(v, reflect.TypeOf(&id).Elem())
+	if err != nil {
+		return id, err
+	}
+
+	return value.Interface().(ID), nil
+}
+
+// assignId generate id by id generator of collection if id field of model is zero,
+// ObjectId is generated if useDefault is true and no generator is set, so that id is known before bulk insert
+func (th *Collection[MODEL, ID]) assignId(ctx context.Context, model any, useDefault bool) error {
+	generator := th.idGenerator
+	if generator == nil {
+		if !useDefault || !th.canHoldObjectId() {
+			return nil
+		}
+		generator = ObjectIdGenerator()
+	}
+
+	if _, ok := model.(MODEL); !ok {
+		return nil
+	}
+
+	modelValue := reflect.ValueOf(model)
+	if modelValue.Kind() != reflect.Ptr || modelValue.IsNil() {
+		return nil
+	}
+
+	if _, zero := th.schema.IdField.ValueOf(modelValue); !zero {
+		return nil
+	}
+
+	id, err := generator.NextId(ctx)
+	if err != nil {
+		return err
+	}
+
+	return th.writeBackId(model, id)
+}
+
+// writeBackId set id to id field of model if it is zero
+func (th *Collection[MODEL, ID]) writeBackId(model any, id any) error {
+	if _, ok := model.(MODEL); !ok || id == nil {
+		return nil
+	}
+
+	modelValue := reflect.ValueOf(model)
+	if modelValue.Kind() != reflect.Ptr || modelValue.IsNil() {
+		return nil
+	}
+
+	if _, zero := th.schema.IdField.ValueOf(modelValue); !zero {
+		return nil
+	}
+
+	value := th.schema.IdField.ReflectValueOf(modelValue)
+	if !value.CanSet() {
+		return nil
+	}
+
+	converted, err := convertValue(id, value.Type())
+	if err != nil {
+		return err
+	}
+	value.Set(converted)
+
+	return nil
+}
+
+// isIdZero whether id field of model is zero, false is returned if model is not pointer of MODEL
+func (th *Collection[MODEL, ID]) isIdZero(model any) bool {
+	if _, ok := model.(MODEL); !ok {
+		return false
+	}
+
+	modelValue := reflect.ValueOf(model)
+	if modelValue.Kind() != reflect.Ptr || modelValue.IsNil() {
+		return false
+	}
+
+	_, zero := th.schema.IdField.ValueOf(modelValue)
+	return zero
+}
+
+// objectIdDocument marshal model to document whose id in hex string is converted to ObjectId,
+// id of other types, such as SObjectId which is marshalled as ObjectId, is kept
+func (th *Collection[MODEL, ID]) objectIdDocument(model any) (bson.D, error) {
+	data, err := bson.Marshal(model)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var doc bson.D
+	if err = bson.Unmarshal(data, &doc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for i, e := range doc {
+		if e.Key != th.schema.IdDBName() {
+			continue
+		}
+		if hex, ok := e.Value.(string); ok {
+			if oid, err := primitive.ObjectIDFromHex(hex); err == nil {
+				doc[i].Value = oid
+			}
+		}
+		break
+	}
+
+	return doc, nil
+}
+
+// idOf id of model, nil is returned if model is not MODEL
+func (th *Collection[MODEL, ID]) idOf(model any) any {
+	if _, ok := model.(MODEL); !ok {
+		return nil
+	}
+
+	modelValue := reflect.ValueOf(model)
+	if modelValue.Kind() == reflect.Ptr && modelValue.IsNil() {
+		return nil
+	}
+	id, _ := th.schema.IdField.ValueOf(modelValue)
+	return id
+}
+
+// canHoldObjectId id field can be ObjectId, SObjectId, string or interface
+func (th *Collection[MODEL, ID]) canHoldObjectId() bool {
+	fieldType := th.schema.IdField.FieldType
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	return fieldType == reflect.TypeOf(primitive.ObjectID{}) ||
+		fieldType.Kind() == reflect.String ||
+		fieldType.Kind() == reflect.Interface
+}
+
+// convertValue convert v to type t, bson codec is tried first, e.g. primitive.ObjectID to SObjectId or string
+func convertValue(v any, t reflect.Type) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	if value.Type().AssignableTo(t) {
+		return value, nil
+	}
+
+	bsonType, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return reflect.Value{}, errors.WithStack(err)
+	}
+
+	converted := reflect.New(t)
+	err = bson.RawValue{Type: bsonType, Value: data}.Unmarshal(converted.Interface())
+	if err == nil {
+		return converted.Elem(), nil
+	}
+
+	if value.Type().ConvertibleTo(t) {
+		return value.Convert(t), nil
+	}
+
+	return reflect.Value{}, errors.WithStack(err)
+}
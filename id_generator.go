@@ -0,0 +1,136 @@
+package jmgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+// IdGenerator generate id before insert, the id is converted to type of id field
+type IdGenerator interface {
+	NextId(ctx context.Context) (any, error)
+}
+
+// IdGeneratorFunc adapter to use function as IdGenerator
+type IdGeneratorFunc func(ctx context.Context) (any, error)
+
+func (th IdGeneratorFunc) NextId(ctx context.Context) (any, error) {
+	return th(ctx)
+}
+
+// ObjectIdGenerator generate primitive.ObjectID
+func ObjectIdGenerator() IdGenerator {
+	return IdGeneratorFunc(func(ctx context.Context) (any, error) {
+		return primitive.NewObjectID(), nil
+	})
+}
+
+// SObjectIdGenerator generate SObjectId
+func SObjectIdGenerator() IdGenerator {
+	return IdGeneratorFunc(func(ctx context.Context) (any, error) {
+		return NewSObjectId(), nil
+	})
+}
+
+// UUIDv4Generator generate random uuid string
+func UUIDv4Generator() IdGenerator {
+	return IdGeneratorFunc(func(ctx context.Context) (any, error) {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return formatUUID(b, 4), nil
+	})
+}
+
+// UUIDv7Generator generate time ordered uuid string
+func UUIDv7Generator() IdGenerator {
+	return IdGeneratorFunc(func(ctx context.Context) (any, error) {
+		var b [16]byte
+		if _, err := rand.Read(b[6:]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// 48 bits unix milli
+		var milli [8]byte
+		binary.BigEndian.PutUint64(milli[:], uint64(time.Now().UnixMilli()))
+		copy(b[0:6], milli[2:])
+		return formatUUID(b, 7), nil
+	})
+}
+
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = (b[6] & 0x0f) | version<<4
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+const (
+	// snowflakeEpoch 2023-01-01 00:00:00 UTC
+	snowflakeEpoch        int64 = 1672531200000
+	snowflakeNodeBits           = 10
+	snowflakeSequenceBits       = 12
+	snowflakeMaxNode            = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSequence        = 1<<snowflakeSequenceBits - 1
+)
+
+// SnowflakeIdGenerator generate sortable int64 id composed of 41 bits milliseconds, 10 bits node and 12 bits sequence
+type SnowflakeIdGenerator struct {
+	mutex    sync.Mutex
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+// NewSnowflakeIdGenerator node must be unique among processes, range is [0, 1023]
+func NewSnowflakeIdGenerator(node int64) (*SnowflakeIdGenerator, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, errors.New(fmt.Sprintf("snowflake node must be between 0 and %d", snowflakeMaxNode))
+	}
+	return &SnowflakeIdGenerator{node: node}, nil
+}
+
+func (th *SnowflakeIdGenerator) NextId(ctx context.Context) (any, error) {
+	return th.Next(), nil
+}
+
+// Next generate next id
+func (th *SnowflakeIdGenerator) Next() int64 {
+	th.mutex.Lock()
+	defer th.mutex.Unlock()
+
+	now := time.Now().UnixMilli()
+	// keep increasing if clock moves backwards
+	if now < th.lastTime {
+		now = th.lastTime
+	}
+
+	if now == th.lastTime {
+		th.sequence = (th.sequence + 1) & snowflakeMaxSequence
+		// sequence is exhausted, wait for next millisecond
+		if th.sequence == 0 {
+			for now <= th.lastTime {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli()
+			}
+		}
+	} else {
+		th.sequence = 0
+	}
+	th.lastTime = now
+
+	return (now-snowflakeEpoch)<<(snowflakeNodeBits+snowflakeSequenceBits) | th.node<<snowflakeSequenceBits | th.sequence
+}
+
+// DefaultCounterCollection collection to store counters
+const DefaultCounterCollection = "_jmgo_counters"
+
+// NewCounterIdGenerator generate int64 id increased by one in counter collection,
+// name is the key of counter, collection name is usually used
+func NewCounterIdGenerator(database *Database, name string) *Sequence {
+	return NewSequence(database, SequenceConfig{Name: name})
+}
@@ -0,0 +1,123 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func Test_IdGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	generator, err := NewSnowflakeIdGenerator(1)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	last := generator.Next()
+	for i := 0; i < 10000; i++ {
+		next := generator.Next()
+		if next <= last {
+			t.Fatalf("snowflake id %d is not greater than %d", next, last)
+		}
+		last = next
+	}
+
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[47][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, g := range []IdGenerator{UUIDv4Generator(), UUIDv7Generator()} {
+		id, err := g.NextId(ctx)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !pattern.MatchString(id.(string)) {
+			t.Fatalf("unexpected uuid %s", id)
+		}
+	}
+}
+
+func Test_AssignId(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+
+	model := &Test{}
+	oid := primitive.NewObjectID()
+	if err := col.writeBackId(model, oid); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if model.Id != SObjectId(oid.Hex()) {
+		t.Fatalf("unexpected id %s", model.Id)
+	}
+
+	model = &Test{}
+	if err := col.assignId(context.Background(), model, true); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !primitive.IsValidObjectID(string(model.Id)) {
+		t.Fatalf("unexpected id %s", model.Id)
+	}
+}
+
+func Test_StringIdDocument(t *testing.T) {
+	type Article struct {
+		Id   string `bson:"_id,omitempty"`
+		Name string `bson:"name"`
+	}
+
+	schema, err := entity.GetOrParse(&Article{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Article, string]{schema: schema, client: &Client{}}
+
+	// string id generated for bulk insert is stored as ObjectId as InsertOne does
+	model := &Article{Name: "abc"}
+	if !col.isIdZero(model) {
+		t.Fatal("id should be zero")
+	}
+	if err = col.assignId(context.Background(), model, true); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	doc, err := col.objectIdDocument(model)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if oid, ok := doc[0].Value.(primitive.ObjectID); !ok || doc[0].Key != "_id" || oid.Hex() != model.Id {
+		t.Fatalf("unexpected document %v", doc)
+	}
+
+	// id is generated on inserted model of upsert unless it is in filter
+	col.SetIdGenerator(UUIDv4Generator())
+	update, inserted, err := col.makeUpsert(context.Background(), bson.M{"name": "abc"}, &Article{Name: "abc"})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if inserted.Id == "" || update["$setOnInsert"].(bson.M)["_id"] != inserted.Id {
+		t.Fatalf("unexpected upsert %v", update)
+	}
+
+	_, inserted, err = col.makeUpsert(context.Background(), bson.M{"_id": "1"}, &Article{Name: "abc"})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if inserted.Id != "" {
+		t.Fatalf("id in filter should be used, got %s", inserted.Id)
+	}
+}
+
+func Test_Sequence(t *testing.T) {
+	at := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	if s := formatSequence("ORD-{yyyy}{MM}{dd}-{seq:6}", 123, at); s != "ORD-20261017-000123" {
+		t.Fatalf("unexpected format %s", s)
+	}
+
+	sequence := &Sequence{config: SequenceConfig{Period: SequencePeriodMonthly}}
+	if key := sequence.periodKey(at); key != "202610" {
+		t.Fatalf("unexpected period %s", key)
+	}
+}
@@ -0,0 +1,210 @@
+package jmgo
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+)
+
+// IndexReport changes made by SyncIndexes, an index whose definition is changed is both dropped and created
+type IndexReport struct {
+	Collection string
+	Created    []string
+	Dropped    []string
+	Unchanged  []string
+	// Unknown indexes exist but are not declared, they are kept unless dropUnknown is specified
+	Unknown []string
+}
+
+// existing index returned by listIndexes
+type indexSpec struct {
+	Name                    string   `bson:"name"`
+	Key                     bson.D   `bson:"key"`
+	Unique                  bool     `bson:"unique"`
+	Sparse                  bool     `bson:"sparse"`
+	ExpireAfterSeconds      *int64   `bson:"expireAfterSeconds"`
+	PartialFilterExpression bson.Raw `bson:"partialFilterExpression"`
+}
+
+// SyncIndexes create indexes declared by jmgo tag and recreate those whose definition is changed,
+// indexes not declared are dropped if dropUnknown is true, index of _id is never dropped
+func (th *Collection[MODEL, ID]) SyncIndexes(ctx context.Context, dropUnknown bool) (*IndexReport, error) {
+	return syncIndexes(ctx, th.collection, th.schema, dropUnknown)
+}
+
+// SyncAllIndexes sync indexes of collections created by NewCollection with any Database of the same client and name,
+// sorted by collection name
+func (th *Database) SyncAllIndexes(ctx context.Context, dropUnknown bool) ([]*IndexReport, error) {
+	var reports []*IndexReport
+	for _, schema := range th.schemas() {
+		report, err := syncIndexes(ctx, th.db.Collection(schema.Collection), schema, dropUnknown)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func syncIndexes(ctx context.Context, collection *mongo.Collection, schema *entity.Entity, dropUnknown bool) (*IndexReport, error) {
+	report := &IndexReport{Collection: collection.Name()}
+
+	existing, err := listIndexes(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]bool{}
+	var models []mongo.IndexModel
+	for _, index := range schema.Indexes {
+		declared[index.Name] = true
+		model := makeIndexModel(index)
+
+		if spec, ok := existing[index.Name]; ok {
+			same, err := isSameIndex(spec, model)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				report.Unchanged = append(report.Unchanged, index.Name)
+				continue
+			}
+
+			if _, err = collection.Indexes().DropOne(ctx, index.Name); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			report.Dropped = append(report.Dropped, index.Name)
+		}
+
+		models = append(models, model)
+	}
+
+	// drop unknown indexes first, an unknown index may have same keys with a declared one
+	var unknown []string
+	for name := range existing {
+		if name != "_id_" && !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, name := range unknown {
+		if !dropUnknown {
+			report.Unknown = append(report.Unknown, name)
+			continue
+		}
+		if _, err = collection.Indexes().DropOne(ctx, name); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		report.Dropped = append(report.Dropped, name)
+	}
+
+	if len(models) > 0 {
+		names, err := collection.Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		report.Created = append(report.Created, names...)
+	}
+
+	return report, nil
+}
+
+func listIndexes(ctx context.Context, collection *mongo.Collection) (map[string]indexSpec, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var specs []indexSpec
+	if err = cursor.All(ctx, &specs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	existing := make(map[string]indexSpec, len(specs))
+	for _, spec := range specs {
+		existing[spec.Name] = spec
+	}
+	return existing, nil
+}
+
+func makeIndexModel(index *entity.Index) mongo.IndexModel {
+	keys := make(bson.D, 0, len(index.Keys))
+	for _, key := range index.Keys {
+		keys = append(keys, bson.E{Key: key.DBName, Value: int32(key.Order)})
+	}
+
+	opt := options.Index().SetName(index.Name)
+	if index.Unique {
+		opt.SetUnique(true)
+	}
+	if index.Sparse {
+		opt.SetSparse(true)
+	}
+	if index.ExpireAfter > 0 {
+		opt.SetExpireAfterSeconds(int32(index.ExpireAfter.Seconds()))
+	}
+	if len(index.PartialFields) > 0 {
+		partial := make(bson.D, 0, len(index.PartialFields))
+		for _, field := range index.PartialFields {
+			partial = append(partial, bson.E{Key: field, Value: bson.D{{Key: "$exists", Value: true}}})
+		}
+		opt.SetPartialFilterExpression(partial)
+	}
+
+	return mongo.IndexModel{Keys: keys, Options: opt}
+}
+
+// isSameIndex compare keys, unique, sparse, ttl and partial filter
+func isSameIndex(spec indexSpec, model mongo.IndexModel) (bool, error) {
+	keys := model.Keys.(bson.D)
+	if len(spec.Key) != len(keys) {
+		return false, nil
+	}
+	for i, key := range keys {
+		// order may be returned as int32, int64 or double
+		order, ok := toInt64(spec.Key[i].Value)
+		if spec.Key[i].Key != key.Key || !ok || order != int64(key.Value.(int32)) {
+			return false, nil
+		}
+	}
+
+	opt := model.Options
+	if spec.Unique != (opt.Unique != nil && *opt.Unique) || spec.Sparse != (opt.Sparse != nil && *opt.Sparse) {
+		return false, nil
+	}
+
+	if (spec.ExpireAfterSeconds == nil) != (opt.ExpireAfterSeconds == nil) {
+		return false, nil
+	}
+	if spec.ExpireAfterSeconds != nil && *spec.ExpireAfterSeconds != int64(*opt.ExpireAfterSeconds) {
+		return false, nil
+	}
+
+	var partial []byte
+	if opt.PartialFilterExpression != nil {
+		data, err := bson.Marshal(opt.PartialFilterExpression)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		partial = data
+	}
+	return bytes.Equal(spec.PartialFilterExpression, partial), nil
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
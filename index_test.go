@@ -0,0 +1,110 @@
+package jmgo
+
+import (
+	"errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type IndexModel struct {
+	Id        SObjectId `bson:"_id"`
+	Email     string    `bson:"email" jmgo:"unique,partial"`
+	UserId    SObjectId `bson:"userId" jmgo:"index=idx_user_time"`
+	CreatedAt time.Time `bson:"createdAt" jmgo:"index=idx_user_time,order=-1"`
+	ExpireAt  time.Time `bson:"expireAt" jmgo:"expireAfter=24h"`
+}
+
+type InvalidIndexModel struct {
+	Id       SObjectId `bson:"_id"`
+	UserId   SObjectId `bson:"userId" jmgo:"index=idx_ttl"`
+	ExpireAt time.Time `bson:"expireAt" jmgo:"index=idx_ttl,expireAfter=24h"`
+}
+
+type SubSecondTTLModel struct {
+	Id       SObjectId `bson:"_id"`
+	ExpireAt time.Time `bson:"expireAt" jmgo:"expireAfter=500ms"`
+}
+
+type FractionTTLModel struct {
+	Id       SObjectId `bson:"_id"`
+	ExpireAt time.Time `bson:"expireAt" jmgo:"expireAfter=1.5s"`
+}
+
+func Test_Indexes(t *testing.T) {
+	schema, err := entity.GetOrParse(&IndexModel{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if len(schema.Indexes) != 3 {
+		t.Fatalf("unexpected indexes %+v", schema.Indexes)
+	}
+
+	email := makeIndexModel(schema.Indexes[0])
+	if *email.Options.Name != "email_1" || !*email.Options.Unique || email.Options.PartialFilterExpression == nil {
+		t.Fatalf("unexpected index %+v", email.Options)
+	}
+
+	compound := makeIndexModel(schema.Indexes[1])
+	keys := compound.Keys.(bson.D)
+	if *compound.Options.Name != "idx_user_time" || len(keys) != 2 || keys[1].Key != "createdAt" || keys[1].Value != int32(-1) {
+		t.Fatalf("unexpected index %+v", keys)
+	}
+
+	ttl := makeIndexModel(schema.Indexes[2])
+	if *ttl.Options.ExpireAfterSeconds != 86400 {
+		t.Fatalf("unexpected ttl %v", *ttl.Options.ExpireAfterSeconds)
+	}
+
+	// existing index returned by server
+	partial, _ := bson.Marshal(bson.D{{Key: "email", Value: bson.D{{Key: "$exists", Value: true}}}})
+	spec := indexSpec{Name: "email_1", Key: bson.D{{Key: "email", Value: float64(1)}}, Unique: true, PartialFilterExpression: partial}
+	if same, _ := isSameIndex(spec, email); !same {
+		t.Fatal("index should be same")
+	}
+	spec.Unique = false
+	if same, _ := isSameIndex(spec, email); same {
+		t.Fatal("index should be changed")
+	}
+
+	if _, err = entity.GetOrParse(&InvalidIndexModel{}); err == nil {
+		t.Fatal("compound ttl index should be rejected")
+	}
+	if _, err = entity.GetOrParse(&SubSecondTTLModel{}); !errors.Is(err, errortype.ErrInvalidIndex) {
+		t.Fatalf("sub-second ttl should be rejected, got %v", err)
+	}
+	if _, err = entity.GetOrParse(&FractionTTLModel{}); !errors.Is(err, errortype.ErrInvalidIndex) {
+		t.Fatalf("fraction of second ttl should be rejected, got %v", err)
+	}
+}
+
+func Test_SchemaRegistry(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	other, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	NewCollection[*IndexModel, SObjectId](&IndexModel{}, NewDatabase(client.Database("registry"), nil))
+
+	// schemas are found by another Database of the same database
+	schemas := NewDatabase(client.Database("registry"), nil).schemas()
+	if len(schemas) != 1 || schemas[0].Collection != "indexModel" {
+		t.Fatalf("unexpected schemas %v", schemas)
+	}
+
+	if schemas = NewDatabase(client.Database("other"), nil).schemas(); len(schemas) != 0 {
+		t.Fatalf("schemas of other database should be empty %v", schemas)
+	}
+	if schemas = NewDatabase(other.Database("registry"), nil).schemas(); len(schemas) != 0 {
+		t.Fatalf("schemas of other client should be empty %v", schemas)
+	}
+}
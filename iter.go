@@ -0,0 +1,112 @@
+package jmgo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultBatchSize batch size used by Iterate if it is not specified in options
+var DefaultBatchSize int32 = 500
+
+// Iter decode documents one at a time
+//
+//	iter, err := col.Iterate(ctx, filter)
+//	if err != nil {
+//		return err
+//	}
+//	defer iter.Close()
+//	for iter.Next() {
+//		model := iter.Value()
+//	}
+//	return iter.Err()
+type Iter[MODEL any] struct {
+	ctx    context.Context
+	cursor *mongo.Cursor
+	value  MODEL
+	err    error
+}
+
+func newIter[MODEL any](ctx context.Context, cursor *mongo.Cursor) *Iter[MODEL] {
+	return &Iter[MODEL]{ctx: ctx, cursor: cursor}
+}
+
+// Next decode next document, return false if there is no more documents, context is done or any error occurs
+func (th *Iter[MODEL]) Next() bool {
+	if th.err != nil {
+		return false
+	}
+
+	if err := th.ctx.Err(); err != nil {
+		th.err = err
+		return false
+	}
+
+	if !th.cursor.Next(th.ctx) {
+		th.err = th.cursor.Err()
+		return false
+	}
+
+	var value MODEL
+	if err := th.cursor.Decode(&value); err != nil {
+		th.err = err
+		return false
+	}
+	th.value = value
+
+	return true
+}
+
+// Value current document
+func (th *Iter[MODEL]) Value() MODEL {
+	return th.value
+}
+
+// Err error occurs while iterating
+func (th *Iter[MODEL]) Err() error {
+	return th.err
+}
+
+func (th *Iter[MODEL]) Close() error {
+	return th.cursor.Close(context.Background())
+}
+
+// Iterate filter type is any,you can use bson.M,bson.D...
+// remember to close the iterator
+func (th *Collection[MODEL, ID]) Iterate(ctx context.Context, filter any, opts ...*options.FindOptions) (*Iter[MODEL], error) {
+
+	convertedFilter, _, err := th.convertFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// options later in the list take precedence
+	opts = append([]*options.FindOptions{options.Find().SetBatchSize(DefaultBatchSize)}, opts...)
+
+	cursor, err := th.collection.Find(ctx, convertedFilter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newIter[MODEL](ctx, cursor), nil
+}
+
+// ForEach call fn for each document, stop iterating if fn returns error
+func (th *Collection[MODEL, ID]) ForEach(ctx context.Context, filter any, fn func(MODEL) error, opts ...*options.FindOptions) error {
+	iter, err := th.Iterate(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	for iter.Next() {
+		if err := fn(iter.Value()); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
@@ -0,0 +1,65 @@
+package jmgo
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func Test_Iter(t *testing.T) {
+	newCursor := func() *mongo.Cursor {
+		cursor, err := mongo.NewCursorFromDocuments([]interface{}{
+			bson.M{"_id": "1", "name": "a"},
+			bson.M{"_id": "2", "name": "b"},
+			bson.M{"_id": "3", "name": "c"},
+		}, nil, nil)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		return cursor
+	}
+
+	iter := newIter[*Test](context.Background(), newCursor())
+	var names []string
+	for iter.Next() {
+		names = append(names, iter.Value().Name)
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(names) != 3 || names[0] != "a" || names[2] != "c" {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	// iterating stops once context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	iter = newIter[*Test](ctx, newCursor())
+	if !iter.Next() || iter.Value().Name != "a" {
+		t.Fatal("expect the first document")
+	}
+	cancel()
+	if iter.Next() || !errors.Is(iter.Err(), context.Canceled) {
+		t.Fatalf("expect canceled, got %v", iter.Err())
+	}
+	// error is kept
+	if iter.Next() || !errors.Is(iter.Err(), context.Canceled) {
+		t.Fatalf("expect canceled, got %v", iter.Err())
+	}
+	_ = iter.Close()
+
+	// document which can not be decoded stops iterating
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{bson.M{"_id": "1", "name": 1}}, nil, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	iter = newIter[*Test](context.Background(), cursor)
+	if iter.Next() || iter.Err() == nil {
+		t.Fatal("expect decode error")
+	}
+	_ = iter.Close()
+}
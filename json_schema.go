@@ -0,0 +1,321 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValidationLevel which documents are validated by validator of collection
+type ValidationLevel string
+
+const (
+	ValidationLevelOff ValidationLevel = "off"
+	// ValidationLevelStrict all inserts and updates are validated
+	ValidationLevelStrict ValidationLevel = "strict"
+	// ValidationLevelModerate updates of existing invalid documents are not validated
+	ValidationLevelModerate ValidationLevel = "moderate"
+)
+
+// ValidationAction what to do with invalid documents
+type ValidationAction string
+
+const (
+	ValidationActionError ValidationAction = "error"
+	ValidationActionWarn  ValidationAction = "warn"
+)
+
+var (
+	sObjectIdType     = reflect.TypeOf(SObjectId(""))
+	mustSObjectIdType = reflect.TypeOf(MustSObjectId(""))
+	objectIdType      = reflect.TypeOf(primitive.ObjectID{})
+	dateTimeType      = reflect.TypeOf(primitive.DateTime(0))
+	decimalType       = reflect.TypeOf(primitive.Decimal128{})
+	valueMarshaler    = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+	marshaler         = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+)
+
+// JSONSchema $jsonSchema generated from model, bson types are derived from field types,
+// required, min, max, len, gt, gte, lt, lte and oneof of validate tag are converted
+func (th *Collection[MODEL, ID]) JSONSchema() bson.M {
+	return makeJSONSchema(th.schema)
+}
+
+// SyncValidator create collection with validator generated by JSONSchema, or update validator by collMod if collection exists
+func (th *Collection[MODEL, ID]) SyncValidator(ctx context.Context, level ValidationLevel, action ValidationAction) error {
+	db := th.collection.Database()
+	name := th.collection.Name()
+	validator := bson.M{"$jsonSchema": th.JSONSchema()}
+
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": name})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(names) == 0 {
+		opt := options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel(string(level)).
+			SetValidationAction(string(action))
+		return errors.WithStack(db.CreateCollection(ctx, name, opt))
+	}
+
+	return errors.WithStack(db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: name},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: string(level)},
+		{Key: "validationAction", Value: string(action)},
+	}).Err())
+}
+
+func makeJSONSchema(schema *entity.Entity) bson.M {
+	properties := bson.M{}
+	required := bson.A{}
+	for _, field := range schema.Fields {
+		property, isRequired := makeJSONSchemaProperty(field.FieldType, field.StructField.Tag.Get("validate"), 0)
+		properties[field.DBName] = property
+		if isRequired {
+			required = append(required, field.DBName)
+		}
+	}
+
+	return makeObjectSchema(properties, required)
+}
+
+func makeObjectSchema(properties bson.M, required bson.A) bson.M {
+	s := bson.M{"bsonType": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// depth limits recursion of self referencing struct
+func makeJSONSchemaProperty(fieldType reflect.Type, validateTag string, depth int) (bson.M, bool) {
+	property := bson.M{}
+
+	nullable := fieldType.Kind() == reflect.Ptr
+	t := entity.Indirect(fieldType)
+
+	bsonTypes := bsonTypesOf(t)
+	switch {
+	case t.Kind() == reflect.Struct && len(bsonTypes) == 1 && bsonTypes[0] == "object" && depth < 8:
+		properties, required := makeStructProperties(t, depth+1)
+		property = makeObjectSchema(properties, required)
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && len(bsonTypes) == 1 && bsonTypes[0] == "array":
+		items, _ := makeJSONSchemaProperty(t.Elem(), "", depth+1)
+		if len(items) > 0 {
+			property["items"] = items
+		}
+	}
+
+	// slice and map may be nil
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		nullable = nullable || len(bsonTypes) > 0
+	}
+
+	if len(bsonTypes) > 0 {
+		if nullable {
+			bsonTypes = append(bsonTypes, "null")
+		}
+		if len(bsonTypes) == 1 {
+			property["bsonType"] = bsonTypes[0]
+		} else {
+			property["bsonType"] = toBsonA(bsonTypes)
+		}
+	}
+
+	required := applyValidateTag(property, t, validateTag, nullable)
+	return property, required
+}
+
+// fields of nested struct, inline struct is flattened
+func makeStructProperties(t reflect.Type, depth int) (bson.M, bson.A) {
+	properties := bson.M{}
+	required := bson.A{}
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		tags := strings.Split(structField.Tag.Get("bson"), ",")
+		if tags[0] == "-" {
+			continue
+		}
+
+		name := tags[0]
+		if name == "" {
+			name = utils.LowerFirst(structField.Name)
+		}
+
+		inline := false
+		for _, tag := range tags[1:] {
+			inline = inline || tag == "inline"
+		}
+
+		if inline && entity.Indirect(structField.Type).Kind() == reflect.Struct {
+			inlineProperties, inlineRequired := makeStructProperties(entity.Indirect(structField.Type), depth)
+			for k, v := range inlineProperties {
+				properties[k] = v
+			}
+			required = append(required, inlineRequired...)
+			continue
+		}
+
+		property, isRequired := makeJSONSchemaProperty(structField.Type, structField.Tag.Get("validate"), depth)
+		properties[name] = property
+		if isRequired {
+			required = append(required, name)
+		}
+	}
+	return properties, required
+}
+
+// bsonTypesOf empty if bson type can not be determined, such as interface or type with custom marshaler
+func bsonTypesOf(t reflect.Type) []string {
+	switch {
+	case t == sObjectIdType:
+		// SObjectId is saved as string if it is not a valid object id
+		return []string{"objectId", "string"}
+	case t == mustSObjectIdType, t == objectIdType:
+		return []string{"objectId"}
+	case t == dateTimeType, entity.IsTimeType(t):
+		return []string{"date"}
+	case t == decimalType:
+		return []string{"decimal"}
+	case t.Implements(valueMarshaler), t.Implements(marshaler), reflect.PtrTo(t).Implements(valueMarshaler), reflect.PtrTo(t).Implements(marshaler):
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return []string{"bool"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return []string{"int"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		// int is saved as int32 if it fits
+		return []string{"int", "long"}
+	case reflect.Float32, reflect.Float64:
+		return []string{"double"}
+	case reflect.String:
+		return []string{"string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return []string{"binData"}
+		}
+		return []string{"array"}
+	case reflect.Array:
+		return []string{"array"}
+	case reflect.Map:
+		return []string{"object"}
+	case reflect.Struct:
+		return []string{"object"}
+	}
+	return nil
+}
+
+// applyValidateTag return whether the field is required, rules after dive are applied to elements and are ignored
+func applyValidateTag(property bson.M, t reflect.Type, tag string, nullable bool) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "min", "gte":
+			applyBound(property, t, param, "minimum", "minLength", "minItems", false)
+		case "max", "lte":
+			applyBound(property, t, param, "maximum", "maxLength", "maxItems", false)
+		case "gt":
+			applyBound(property, t, param, "minimum", "minLength", "minItems", true)
+		case "lt":
+			applyBound(property, t, param, "maximum", "maxLength", "maxItems", true)
+		case "len":
+			applyBound(property, t, param, "minimum", "minLength", "minItems", false)
+			applyBound(property, t, param, "maximum", "maxLength", "maxItems", false)
+		case "oneof":
+			enum := bson.A{}
+			for _, value := range strings.Fields(param) {
+				if v, ok := parseNumber(t, value); ok {
+					enum = append(enum, v)
+				} else {
+					enum = append(enum, value)
+				}
+			}
+			if nullable {
+				enum = append(enum, nil)
+			}
+			property["enum"] = enum
+		}
+	}
+	return required
+}
+
+// applyBound number is bounded by value, string by length and slice by number of items
+func applyBound(property bson.M, t reflect.Type, param string, numberKey string, lengthKey string, itemsKey string, exclusive bool) {
+	switch t.Kind() {
+	case reflect.String:
+		if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+			if exclusive {
+				n = exclusiveLength(numberKey, n)
+			}
+			property[lengthKey] = n
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+			if exclusive {
+				n = exclusiveLength(numberKey, n)
+			}
+			if t.Kind() == reflect.Map {
+				itemsKey = strings.Replace(itemsKey, "Items", "Properties", 1)
+			}
+			property[itemsKey] = n
+		}
+	default:
+		if v, ok := parseNumber(t, param); ok {
+			property[numberKey] = v
+			if exclusive {
+				property["exclusive"+strings.ToUpper(numberKey[:1])+numberKey[1:]] = true
+			}
+		}
+	}
+}
+
+// gt=3 means length >= 4, lt=3 means length <= 2
+func exclusiveLength(numberKey string, n int64) int64 {
+	if numberKey == "minimum" {
+		return n + 1
+	}
+	return n - 1
+}
+
+func parseNumber(t reflect.Type, s string) (any, bool) {
+	if entity.IsIntegerType(t) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil
+	}
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	return nil, false
+}
+
+func toBsonA(values []string) bson.A {
+	a := make(bson.A, 0, len(values))
+	for _, v := range values {
+		a = append(a, v)
+	}
+	return a
+}
@@ -0,0 +1,62 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+type SchemaAddress struct {
+	City string `bson:"city" validate:"required"`
+}
+
+type SchemaBase struct {
+	Id SObjectId `bson:"_id"`
+}
+
+type SchemaModel struct {
+	SchemaBase `bson:",inline"`
+	Name       string         `bson:"name" validate:"required,min=2,max=20"`
+	Age        *int           `bson:"age" validate:"gte=0,lt=150"`
+	Status     string         `bson:"status" validate:"oneof=active disabled"`
+	Tags       []string       `bson:"tags" validate:"max=5,dive,min=1"`
+	Address    SchemaAddress  `bson:"address"`
+	CreatedAt  MilliTime      `bson:"createdAt"`
+	Extra      map[string]any `bson:"extra"`
+}
+
+func Test_JSONSchema(t *testing.T) {
+	schema, err := entity.GetOrParse(&SchemaModel{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	s := makeJSONSchema(schema)
+	if !reflect.DeepEqual(s["required"], bson.A{"name"}) {
+		t.Fatalf("unexpected required %v", s["required"])
+	}
+
+	properties := s["properties"].(bson.M)
+	expected := map[string]bson.M{
+		"_id":       {"bsonType": bson.A{"objectId", "string"}},
+		"name":      {"bsonType": "string", "minLength": int64(2), "maxLength": int64(20)},
+		"age":       {"bsonType": bson.A{"int", "long", "null"}, "minimum": int64(0), "maximum": int64(150), "exclusiveMaximum": true},
+		"status":    {"bsonType": "string", "enum": bson.A{"active", "disabled"}},
+		"tags":      {"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "string"}, "maxItems": int64(5)},
+		"createdAt": {"bsonType": "date"},
+		"extra":     {"bsonType": bson.A{"object", "null"}},
+		"address": {"bsonType": "object", "required": bson.A{"city"}, "properties": bson.M{
+			"city": bson.M{"bsonType": "string"},
+		}},
+	}
+
+	if len(properties) != len(expected) {
+		t.Fatalf("unexpected properties %v", properties)
+	}
+	for name, property := range expected {
+		if !reflect.DeepEqual(properties[name], property) {
+			t.Fatalf("unexpected property %s: %v", name, properties[name])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package migrate runs migrations registered by jmgo.Register from command line.
+//
+// migrations are Go code, so they must be linked into the binary running them. build a command
+// importing the packages defining migrations, whose init calls jmgo.Register:
+//
+//	package main
+//
+//	import (
+//		"github.com/wsk-go/jmgo/migrate"
+//		_ "example.com/app/migrations"
+//	)
+//
+//	func main() {
+//		migrate.Main()
+//	}
+//
+// and run it as
+//
+//	app-migrate -uri mongodb://localhost:27017 -db app migrate up|down|status
+package migrate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/wsk-go/jmgo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"os/signal"
+)
+
+// Main parse flags of command line, run migrate command and exit
+func Main() {
+	uri := flag.String("uri", os.Getenv("JMGO_URI"), "mongodb connection string, default is $JMGO_URI")
+	database := flag.String("db", os.Getenv("JMGO_DB"), "database name, default is $JMGO_DB")
+	collection := flag.String("collection", jmgo.DefaultMigrationCollection, "collection recording applied migrations")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "usage: "+os.Args[0]+" [flags] migrate up|down|status [-dry-run] [target]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if *uri == "" || *database == "" || len(args) < 2 || args[0] != "migrate" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*uri, *database, *collection, args[1:]); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%+v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(uri string, database string, collection string, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	client, err := jmgo.NewClient(jmgo.ClientConfig{Opts: []*options.ClientOptions{options.Client().ApplyURI(uri)}})
+	if err != nil {
+		return err
+	}
+
+	if err = client.Connect(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Client().Disconnect(context.Background())
+	}()
+
+	db := client.Database(database).RegisterMigrations(jmgo.RegisteredMigrations()...)
+	return jmgo.MigrateCommand(ctx, db, args, os.Stdout, jmgo.MigrateConfig{Collection: collection})
+}
@@ -0,0 +1,368 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMigrationCollection collection recording applied migrations
+const DefaultMigrationCollection = "_jmgo_migrations"
+
+// id of lock document in migration collection
+const migrationLockId = "_lock"
+
+// Migration migrations are applied in ascending order of ID, so prefix ID with date, e.g. "20260101_add_user_index"
+type Migration struct {
+	ID   string
+	Up   func(ctx context.Context, db *Database) error
+	Down func(ctx context.Context, db *Database) error
+}
+
+type MigrateConfig struct {
+	// DryRun migrations to be applied or rolled back are returned without running them
+	DryRun bool
+	// Collection recording applied migrations, DefaultMigrationCollection is used if it is empty
+	Collection string
+	// LockTimeout lock held longer than timeout is considered abandoned, default is 10 minutes and minimum is 1 second
+	LockTimeout time.Duration
+}
+
+// MigrationStatus status of registered or applied migration
+type MigrationStatus struct {
+	ID string
+	// Applied whether the migration has been applied
+	Applied bool
+	// AppliedAt time when the migration was applied
+	AppliedAt time.Time
+	// Registered false if the migration was applied but is not registered any more
+	Registered bool
+}
+
+type migrationRecord struct {
+	ID        string    `bson:"_id"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+var (
+	// migrations registered by Register, usually in init
+	registeredMigrations []Migration
+	registeredMutex      sync.Mutex
+)
+
+// Register register migrations globally, usually in init of the package defining them.
+// they are not run by any database until they are passed to RegisterMigrations, e.g.
+//
+//	db.RegisterMigrations(jmgo.RegisteredMigrations()...)
+//
+// which is what the migrate command does
+func Register(migrations ...Migration) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+	registeredMigrations = append(registeredMigrations, migrations...)
+}
+
+// RegisteredMigrations migrations registered by Register
+func RegisteredMigrations() []Migration {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+	return append([]Migration(nil), registeredMigrations...)
+}
+
+// RegisterMigrations register migrations run by Migrate and Rollback
+func (th *Database) RegisterMigrations(migrations ...Migration) *Database {
+	th.migrationMutex.Lock()
+	defer th.migrationMutex.Unlock()
+	th.migrations = append(th.migrations, migrations...)
+	return th
+}
+
+// Migrate apply registered migrations which have not been applied, return ids of applied migrations
+func (th *Database) Migrate(ctx context.Context, config MigrateConfig) ([]string, error) {
+	migrations, err := th.sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	err = th.withMigrationLock(ctx, config, func(ctx context.Context, collection *mongo.Collection) error {
+		applied, err := appliedMigrations(ctx, collection)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if _, ok := applied[migration.ID]; ok {
+				continue
+			}
+			if err = ctx.Err(); err != nil {
+				return errors.WithStack(err)
+			}
+
+			if !config.DryRun {
+				if err = migration.Up(ctx, th); err != nil {
+					return errors.WithMessagef(err, "migration %s", migration.ID)
+				}
+
+				_, err = collection.InsertOne(ctx, migrationRecord{ID: migration.ID, AppliedAt: th.now()})
+				if err != nil {
+					return errors.WithStack(err)
+				}
+			}
+
+			ids = append(ids, migration.ID)
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// Rollback roll back applied migrations after target in descending order, target itself is kept,
+// all applied migrations are rolled back if target is empty. return ids of rolled back migrations
+func (th *Database) Rollback(ctx context.Context, target string, config MigrateConfig) ([]string, error) {
+	migrations, err := th.sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	err = th.withMigrationLock(ctx, config, func(ctx context.Context, collection *mongo.Collection) error {
+		applied, err := appliedMigrations(ctx, collection)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if migration.ID <= target {
+				break
+			}
+			if _, ok := applied[migration.ID]; !ok {
+				continue
+			}
+
+			if migration.Down == nil {
+				return errors.WithStack(fmt.Errorf("%w: %s", errortype.ErrMigrationIrreversible, migration.ID))
+			}
+			if err = ctx.Err(); err != nil {
+				return errors.WithStack(err)
+			}
+
+			if !config.DryRun {
+				if err = migration.Down(ctx, th); err != nil {
+					return errors.WithMessagef(err, "migration %s", migration.ID)
+				}
+
+				_, err = collection.DeleteOne(ctx, bson.M{"_id": migration.ID})
+				if err != nil {
+					return errors.WithStack(err)
+				}
+			}
+
+			ids = append(ids, migration.ID)
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// MigrationStatus status of registered migrations and applied migrations which are not registered, sorted by id
+func (th *Database) MigrationStatus(ctx context.Context, config MigrateConfig) ([]MigrationStatus, error) {
+	migrations, err := th.sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, th.db.Collection(migrationCollectionOf(config)))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		record, ok := applied[migration.ID]
+		statuses = append(statuses, MigrationStatus{
+			ID:         migration.ID,
+			Applied:    ok,
+			AppliedAt:  record.AppliedAt,
+			Registered: true,
+		})
+		delete(applied, migration.ID)
+	}
+
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{ID: record.ID, Applied: true, AppliedAt: record.AppliedAt})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+
+	return statuses, nil
+}
+
+// sortedMigrations migrations sorted by id, id must be unique and Up must be specified
+func (th *Database) sortedMigrations() ([]Migration, error) {
+	th.migrationMutex.Lock()
+	migrations := append([]Migration(nil), th.migrations...)
+	th.migrationMutex.Unlock()
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].ID < migrations[j].ID
+	})
+
+	for i, migration := range migrations {
+		if migration.ID == "" || migration.ID == migrationLockId || migration.Up == nil {
+			return nil, errors.WithStack(fmt.Errorf("%w: id %q must not be empty or %q and Up must be specified", errortype.ErrInvalidMigration, migration.ID, migrationLockId))
+		}
+		if i > 0 && migrations[i-1].ID == migration.ID {
+			return nil, errors.WithStack(fmt.Errorf("%w: duplicate id %s", errortype.ErrInvalidMigration, migration.ID))
+		}
+	}
+
+	return migrations, nil
+}
+
+// withMigrationLock lock document is inserted by upsert, upsert of a lock held by others fails with duplicate key.
+// lock is renewed while fn runs, if it is lost, context passed to fn is canceled and ErrMigrationLockLost is returned.
+// dry run does not change anything, so lock is not required
+func (th *Database) withMigrationLock(ctx context.Context, config MigrateConfig, fn func(ctx context.Context, collection *mongo.Collection) error) error {
+	collection := th.db.Collection(migrationCollectionOf(config))
+	if config.DryRun {
+		return fn(ctx, collection)
+	}
+
+	timeout := lockTimeoutOf(config)
+	owner := primitive.NewObjectID().Hex()
+	now := th.now()
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": migrationLockId, "expireAt": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"owner": owner, "lockedAt": now, "expireAt": now.Add(timeout)}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.WithStack(errortype.ErrMigrationLocked)
+		}
+		return errors.WithStack(err)
+	}
+
+	defer func() {
+		// lock is released even if context is canceled
+		_, _ = collection.DeleteOne(context.Background(), bson.M{"_id": migrationLockId, "owner": owner})
+	}()
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := make(chan struct{})
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if !th.renewMigrationLock(lockCtx, collection, owner, timeout, done) {
+			close(lost)
+			cancel()
+		}
+	}()
+
+	err = fn(lockCtx, collection)
+	close(done)
+	<-stopped
+
+	select {
+	case <-lost:
+		if err != nil {
+			return errors.WithStack(fmt.Errorf("%w: %v", errortype.ErrMigrationLockLost, err))
+		}
+		return errors.WithStack(errortype.ErrMigrationLockLost)
+	default:
+		return err
+	}
+}
+
+// lockTimeoutOf lock timeout of config, lock is renewed every third of timeout, so timeout
+// shorter than minimum is raised, it would make renewal too frequent or panic the ticker
+func lockTimeoutOf(config MigrateConfig) time.Duration {
+	timeout := config.LockTimeout
+	if timeout <= 0 {
+		return 10 * time.Minute
+	}
+	if timeout < time.Second {
+		return time.Second
+	}
+	return timeout
+}
+
+// renewMigrationLock extend expireAt of lock periodically until done is closed, return false if lock is lost,
+// that is, it is taken by others or it can not be renewed before it expires
+func (th *Database) renewMigrationLock(ctx context.Context, collection *mongo.Collection, owner string, timeout time.Duration, done <-chan struct{}) bool {
+	ticker := time.NewTicker(timeout / 3)
+	defer ticker.Stop()
+
+	expireAt := th.now().Add(timeout)
+	for {
+		select {
+		case <-done:
+			return true
+		case <-ticker.C:
+		}
+
+		now := th.now()
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"_id": migrationLockId, "owner": owner},
+			bson.M{"$set": bson.M{"expireAt": now.Add(timeout)}},
+		)
+		switch {
+		case err == nil && result.MatchedCount == 0:
+			return false
+		case err == nil:
+			expireAt = now.Add(timeout)
+		case !now.Before(expireAt):
+			return false
+		}
+	}
+}
+
+func (th *Database) now() time.Time {
+	if th.client != nil && th.client.Now != nil {
+		return th.client.Now()
+	}
+	return time.Now()
+}
+
+func migrationCollectionOf(config MigrateConfig) string {
+	if config.Collection != "" {
+		return config.Collection
+	}
+	return DefaultMigrationCollection
+}
+
+// appliedMigrations records by id, lock document is excluded
+func appliedMigrations(ctx context.Context, collection *mongo.Collection) (map[string]migrationRecord, error) {
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockId}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var records []migrationRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	applied := make(map[string]migrationRecord, len(records))
+	for _, record := range records {
+		applied[record.ID] = record
+	}
+	return applied, nil
+}
@@ -0,0 +1,103 @@
+package jmgo
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// MigrateCommand run command "up|down|status" with migrations registered on db, used by package migrate.
+//
+//	up [-dry-run]             apply all pending migrations
+//	down [-dry-run] [target]  roll back the last applied migration, or all migrations after target
+//	status                    list registered and applied migrations
+func MigrateCommand(ctx context.Context, db *Database, args []string, out io.Writer, config MigrateConfig) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.BoolVar(&config.DryRun, "dry-run", config.DryRun, "print migrations without running them")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	prefix := ""
+	if config.DryRun {
+		prefix = "(dry run) "
+	}
+
+	switch args[0] {
+	case "up":
+		ids, err := db.Migrate(ctx, config)
+		for _, id := range ids {
+			_, _ = fmt.Fprintf(out, "%sapplied %s\n", prefix, id)
+		}
+		if err == nil && len(ids) == 0 {
+			_, _ = fmt.Fprintln(out, "no pending migrations")
+		}
+		return err
+	case "down":
+		var target string
+		if flags.NArg() > 0 {
+			target = flags.Arg(0)
+		} else {
+			// roll back the last applied migration only
+			last, err := lastAppliedTarget(ctx, db, config)
+			if err != nil {
+				return err
+			}
+			target = last
+		}
+
+		ids, err := db.Rollback(ctx, target, config)
+		for _, id := range ids {
+			_, _ = fmt.Fprintf(out, "%srolled back %s\n", prefix, id)
+		}
+		if err == nil && len(ids) == 0 {
+			_, _ = fmt.Fprintln(out, "nothing to roll back")
+		}
+		return err
+	case "status":
+		statuses, err := db.MigrationStatus(ctx, config)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if !status.Registered {
+				state += " (not registered)"
+			}
+			_, _ = fmt.Fprintf(out, "%-40s %s\n", status.ID, state)
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown migrate command %s, usage: migrate up|down|status", args[0])
+	}
+}
+
+// lastAppliedTarget id of the applied registered migration before the last one
+func lastAppliedTarget(ctx context.Context, db *Database, config MigrateConfig) (string, error) {
+	statuses, err := db.MigrationStatus(ctx, config)
+	if err != nil {
+		return "", err
+	}
+
+	var applied []string
+	for _, status := range statuses {
+		if status.Applied && status.Registered {
+			applied = append(applied, status.ID)
+		}
+	}
+
+	if len(applied) < 2 {
+		return "", nil
+	}
+	return applied[len(applied)-2], nil
+}
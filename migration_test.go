@@ -0,0 +1,51 @@
+package jmgo
+
+import (
+	"context"
+	"errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"testing"
+	"time"
+)
+
+func Test_SortedMigrations(t *testing.T) {
+	up := func(ctx context.Context, db *Database) error { return nil }
+
+	db := &Database{}
+	db.RegisterMigrations(
+		Migration{ID: "20260102_b", Up: up},
+		Migration{ID: "20260101_a", Up: up},
+	)
+
+	migrations, err := db.sortedMigrations()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if migrations[0].ID != "20260101_a" || migrations[1].ID != "20260102_b" {
+		t.Fatalf("unexpected order %v %v", migrations[0].ID, migrations[1].ID)
+	}
+
+	db.RegisterMigrations(Migration{ID: "20260101_a", Up: up})
+	if _, err = db.sortedMigrations(); !errors.Is(err, errortype.ErrInvalidMigration) {
+		t.Fatalf("duplicate id should be rejected, got %v", err)
+	}
+
+	db = (&Database{}).RegisterMigrations(Migration{ID: "20260101_a"})
+	if _, err = db.sortedMigrations(); !errors.Is(err, errortype.ErrInvalidMigration) {
+		t.Fatalf("migration without Up should be rejected, got %v", err)
+	}
+}
+
+func Test_LockTimeout(t *testing.T) {
+	for timeout, expected := range map[time.Duration]time.Duration{
+		0:                   10 * time.Minute,
+		-time.Second:        10 * time.Minute,
+		time.Nanosecond:     time.Second,
+		2 * time.Nanosecond: time.Second,
+		time.Minute:         time.Minute,
+	} {
+		if actual := lockTimeoutOf(MigrateConfig{LockTimeout: timeout}); actual != expected {
+			t.Fatalf("lock timeout of %v should be %v, got %v", timeout, expected, actual)
+		}
+	}
+}
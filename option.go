@@ -19,8 +19,10 @@ type FindOption struct {
 	skip        int
 	limit       int
 	total       *int64
+	facet       *bool
 	includes    []string
 	excludes    []string
+	preloads    []string
 	sorts       []*Sort
 	findOneOpts []*options.FindOneOptions
 	findOpts    []*options.FindOptions
@@ -45,6 +47,19 @@ func (th *FindOption) WithTotal(total *int64) *FindOption {
 	return th
 }
 
+// Facet whether to use $facet to get total and items in a single round-trip,
+// it overrides the default of collection
+func (th *FindOption) Facet(facet bool) *FindOption {
+	th.facet = &facet
+	return th
+}
+
+// Preload fill companion fields of relations after find, nested relation is separated by dot, e.g. "Order.User"
+func (th *FindOption) Preload(relations ...string) *FindOption {
+	th.preloads = append(th.preloads, relations...)
+	return th
+}
+
 // AddIncludes 要选择的属性，注意用模型定义的属性名字，而不是
 func (th *FindOption) AddIncludes(includes ...string) *FindOption {
 	th.includes = append(th.includes, includes...)
@@ -104,6 +119,10 @@ func Merge(options []*FindOption) *FindOption {
 			current.total = o.total
 		}
 
+		if o.facet != nil {
+			current.facet = o.facet
+		}
+
 		if o.excludes != nil {
 			current.excludes = append(current.excludes, o.excludes...)
 		}
@@ -112,6 +131,10 @@ func Merge(options []*FindOption) *FindOption {
 			current.includes = append(current.includes, o.includes...)
 		}
 
+		if o.preloads != nil {
+			current.preloads = append(current.preloads, o.preloads...)
+		}
+
 		if o.sorts != nil {
 			current.sorts = append(current.sorts, o.sorts...)
 		}
@@ -0,0 +1,48 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func Test_FindOption_MakeFindOption(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var total int64
+	option := Option().Offset(10).Limit(5).WithTotal(&total).AddIncludes("Name", "happy").AddOrder("Age", false)
+	findOpts, err := option.makeFindOption(schema)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	opt := findOpts[0]
+	if *opt.Skip != 10 || *opt.Limit != 5 {
+		t.Fatalf("unexpected skip %d or limit %d", *opt.Skip, *opt.Limit)
+	}
+
+	projection := opt.Projection.(bson.D)
+	if len(projection) != 2 || projection[0].Key != "name" || projection[1].Key != "happy" {
+		t.Fatalf("unexpected projection %v", projection)
+	}
+
+	sort := opt.Sort.(bson.D)
+	if len(sort) != 1 || sort[0].Key != "happy" || sort[0].Value != -1 {
+		t.Fatalf("unexpected sort %v", sort)
+	}
+
+	_, err = Option().AddIncludes("NotExists").makeFindOption(schema)
+	if err == nil {
+		t.Fatal("expect error for unknown field")
+	}
+}
+
+func Test_FindOption_Merge(t *testing.T) {
+	merged := Option().Offset(20).Merge([]*FindOption{Option().Offset(1).Limit(3)})
+	if merged.skip != 20 || merged.limit != 3 {
+		t.Fatalf("unexpected merged option skip %d limit %d", merged.skip, merged.limit)
+	}
+}
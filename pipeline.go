@@ -0,0 +1,359 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// Accumulator accumulator used by Group and Bucket, it is created by functions prefixed with Acc, e.g. AccSum
+type Accumulator struct {
+	operator   string
+	expression any
+}
+
+func AccSum(expression any) Accumulator {
+	return Accumulator{operator: "$sum", expression: expression}
+}
+
+// AccCount number of documents in group
+func AccCount() Accumulator {
+	return Accumulator{operator: "$sum", expression: 1}
+}
+
+func AccAvg(expression any) Accumulator {
+	return Accumulator{operator: "$avg", expression: expression}
+}
+
+func AccMin(expression any) Accumulator {
+	return Accumulator{operator: "$min", expression: expression}
+}
+
+func AccMax(expression any) Accumulator {
+	return Accumulator{operator: "$max", expression: expression}
+}
+
+func AccFirst(expression any) Accumulator {
+	return Accumulator{operator: "$first", expression: expression}
+}
+
+func AccLast(expression any) Accumulator {
+	return Accumulator{operator: "$last", expression: expression}
+}
+
+func AccPush(expression any) Accumulator {
+	return Accumulator{operator: "$push", expression: expression}
+}
+
+func AccAddToSet(expression any) Accumulator {
+	return Accumulator{operator: "$addToSet", expression: expression}
+}
+
+// Accumulators output fields of Group and Bucket
+type Accumulators map[string]Accumulator
+
+// pipelineContext state while building pipeline
+type pipelineContext struct {
+	schema        *entity.Entity
+	convertFilter func(filter any) (any, error)
+	// documents still have the shape of model, it is false after stages such as $group
+	modelShape bool
+}
+
+type pipelineStage func(ctx *pipelineContext) (bson.M, error)
+
+// PipelineBuilder build aggregation pipeline, field names and field paths in expressions such as "$Age"
+// are translated to db names by schema of collection until the shape of document is changed by
+// $group, $bucket, $facet or $replaceRoot
+//
+//	jmgo.Pipeline().Match(filter).Group("$Name", jmgo.Accumulators{"total": jmgo.AccSum("$Age")}).Sort("-total")
+type PipelineBuilder struct {
+	stages []pipelineStage
+}
+
+// Pipeline create a pipeline builder
+func Pipeline() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+func (th *PipelineBuilder) add(stage pipelineStage) *PipelineBuilder {
+	th.stages = append(th.stages, stage)
+	return th
+}
+
+// Match filter type is any, you can use bson.M, bson.D, filter struct...
+func (th *PipelineBuilder) Match(filter any) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		query, err := ctx.convertFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$match": query}, nil
+	})
+}
+
+// Group id is an expression, e.g. "$Name" or bson.M{"name": "$Name", "age": "$Age"}
+func (th *PipelineBuilder) Group(id any, accumulators Accumulators) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		group := ctx.accumulators(accumulators)
+		group["_id"] = ctx.expression(id)
+		ctx.modelShape = false
+		return bson.M{"$group": group}, nil
+	})
+}
+
+// Project keys are field names and values are 0, 1 or expressions
+func (th *PipelineBuilder) Project(projection bson.M) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return bson.M{"$project": ctx.fields(projection)}, nil
+	})
+}
+
+// AddFields keys are field names and values are expressions
+func (th *PipelineBuilder) AddFields(fields bson.M) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return bson.M{"$addFields": ctx.fields(fields)}, nil
+	})
+}
+
+// Sort field with prefix '-' means sort from large to small, e.g. Sort("-CreatedAt", "Name")
+func (th *PipelineBuilder) Sort(fields ...string) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		sort := make(bson.D, 0, len(fields))
+		for _, field := range fields {
+			order := 1
+			if strings.HasPrefix(field, "-") {
+				order = -1
+				field = field[1:]
+			}
+			sort = append(sort, primitive.E{Key: ctx.name(strings.TrimPrefix(field, "+")), Value: order})
+		}
+		return bson.M{"$sort": sort}, nil
+	})
+}
+
+func (th *PipelineBuilder) Skip(skip int64) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return bson.M{"$skip": skip}, nil
+	})
+}
+
+func (th *PipelineBuilder) Limit(limit int64) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return bson.M{"$limit": limit}, nil
+	})
+}
+
+// Lookup localField is name of field in model, foreignField is db name of field in the other collection
+func (th *PipelineBuilder) Lookup(from string, localField string, foreignField string, as string) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return bson.M{"$lookup": bson.M{
+			"from":         from,
+			"localField":   ctx.name(localField),
+			"foreignField": foreignField,
+			"as":           as,
+		}}, nil
+	})
+}
+
+// Unwind field is name of array field
+func (th *PipelineBuilder) Unwind(field string, preserveNullAndEmptyArrays bool) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return bson.M{"$unwind": bson.M{
+			"path":                       "$" + ctx.name(strings.TrimPrefix(field, "$")),
+			"preserveNullAndEmptyArrays": preserveNullAndEmptyArrays,
+		}}, nil
+	})
+}
+
+// Facet each sub-pipeline is built on the same input documents
+func (th *PipelineBuilder) Facet(facets map[string]*PipelineBuilder) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		facet := bson.M{}
+		for name, pipeline := range facets {
+			subCtx := *ctx
+			stages, err := pipeline.build(&subCtx)
+			if err != nil {
+				return nil, err
+			}
+			facet[name] = stages
+		}
+		ctx.modelShape = false
+		return bson.M{"$facet": facet}, nil
+	})
+}
+
+// Bucket output is optional, defaultBucket is ignored if it is nil
+func (th *PipelineBuilder) Bucket(groupBy any, boundaries []any, defaultBucket any, output Accumulators) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		bucket := bson.M{
+			"groupBy":    ctx.expression(groupBy),
+			"boundaries": boundaries,
+		}
+		if defaultBucket != nil {
+			bucket["default"] = defaultBucket
+		}
+		if len(output) > 0 {
+			bucket["output"] = ctx.accumulators(output)
+		}
+		ctx.modelShape = false
+		return bson.M{"$bucket": bucket}, nil
+	})
+}
+
+// ReplaceRoot newRoot is an expression, e.g. "$Address"
+func (th *PipelineBuilder) ReplaceRoot(newRoot any) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		root := ctx.expression(newRoot)
+		ctx.modelShape = false
+		return bson.M{"$replaceRoot": bson.M{"newRoot": root}}, nil
+	})
+}
+
+// Stage raw stage which is not translated
+func (th *PipelineBuilder) Stage(stage bson.M) *PipelineBuilder {
+	return th.add(func(ctx *pipelineContext) (bson.M, error) {
+		return stage, nil
+	})
+}
+
+func (th *PipelineBuilder) build(ctx *pipelineContext) (bson.A, error) {
+	stages := make(bson.A, 0, len(th.stages))
+	for _, stage := range th.stages {
+		s, err := stage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
+
+// name translate name of field to db name, only the first part of path is translated
+func (th *pipelineContext) name(name string) string {
+	if !th.modelShape {
+		return name
+	}
+
+	if field := th.schema.LookUpField(name); field != nil {
+		return field.DBName
+	}
+
+	if index := strings.Index(name, "."); index > 0 {
+		if field := th.schema.LookUpField(name[:index]); field != nil {
+			return field.DBName + name[index:]
+		}
+	}
+
+	return name
+}
+
+// expression translate field paths in expression, variables such as "$$ROOT" are kept
+func (th *pipelineContext) expression(expression any) any {
+	switch v := expression.(type) {
+	case string:
+		if strings.HasPrefix(v, "$") && !strings.HasPrefix(v, "$$") {
+			return "$" + th.name(v[1:])
+		}
+		return v
+	case Accumulator:
+		return bson.M{v.operator: th.expression(v.expression)}
+	case bson.M:
+		m := make(bson.M, len(v))
+		for key, value := range v {
+			m[key] = th.expression(value)
+		}
+		return m
+	case bson.D:
+		d := make(bson.D, 0, len(v))
+		for _, e := range v {
+			d = append(d, primitive.E{Key: e.Key, Value: th.expression(e.Value)})
+		}
+		return d
+	case bson.A:
+		a := make(bson.A, 0, len(v))
+		for _, value := range v {
+			a = append(a, th.expression(value))
+		}
+		return a
+	case []any:
+		return th.expression(bson.A(v))
+	default:
+		return v
+	}
+}
+
+// fields translate keys as field names and values as expressions
+func (th *pipelineContext) fields(fields bson.M) bson.M {
+	m := make(bson.M, len(fields))
+	for key, value := range fields {
+		m[th.name(key)] = th.expression(value)
+	}
+	return m
+}
+
+func (th *pipelineContext) accumulators(accumulators Accumulators) bson.M {
+	m := make(bson.M, len(accumulators)+1)
+	for key, accumulator := range accumulators {
+		m[key] = th.expression(accumulator)
+	}
+	return m
+}
+
+// buildPipeline documents marked as deleted are excluded at the beginning of pipeline
+func (th *Collection[MODEL, ID]) buildPipeline(pipeline *PipelineBuilder) (bson.A, error) {
+	ctx := &pipelineContext{
+		schema: th.schema,
+		convertFilter: func(filter any) (any, error) {
+			query, _, err := th.doConvertFilter(filter)
+			return query, err
+		},
+		modelShape: true,
+	}
+
+	stages, err := pipeline.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope, ok := th.applyDeletedScope(bson.M{}).(bson.M); ok && len(scope) > 0 {
+		stages = append(bson.A{bson.M{"$match": scope}}, stages...)
+	}
+
+	return stages, nil
+}
+
+// AggregateAs aggregate and decode results into R
+func AggregateAs[R any, MODEL any, ID any](ctx context.Context, col *Collection[MODEL, ID], pipeline *PipelineBuilder, opts ...*options.AggregateOptions) ([]R, error) {
+	stages, err := col.buildPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []R
+	err = col.Aggregate(ctx, stages, &out, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// AggregateIter aggregate and decode results into R one at a time, remember to close the iterator
+func AggregateIter[R any, MODEL any, ID any](ctx context.Context, col *Collection[MODEL, ID], pipeline *PipelineBuilder, opts ...*options.AggregateOptions) (*Iter[R], error) {
+	stages, err := col.buildPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := col.collection.Aggregate(ctx, stages, opts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return newIter[R](ctx, cursor), nil
+}
@@ -0,0 +1,39 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+func Test_Pipeline(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+
+	stages, err := col.buildPipeline(Pipeline().
+		Match(bson.M{"name": "abc"}).
+		Sort("-Age").
+		Group("$Name", Accumulators{"total": AccSum("$Age"), "count": AccCount()}).
+		Sort("-total"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	expected := bson.A{
+		bson.M{"$match": bson.M{"name": "abc"}},
+		bson.M{"$sort": bson.D{{Key: "happy", Value: -1}}},
+		bson.M{"$group": bson.M{
+			"_id":   "$name",
+			"total": bson.M{"$sum": "$happy"},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.D{{Key: "total", Value: -1}}},
+	}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Fatalf("unexpected pipeline %v", stages)
+	}
+}
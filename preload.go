@@ -0,0 +1,257 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reflect"
+	"strings"
+)
+
+// Preload fill companion fields of relations declared by jmgo tag ref or refBy.
+// referenced models of each relation are fetched by a single $in query, nested relation is separated by dot, e.g. "Order.User"
+func (th *Collection[MODEL, ID]) Preload(ctx context.Context, models []MODEL, relations ...string) error {
+	if len(models) == 0 || len(relations) == 0 {
+		return nil
+	}
+
+	values := make([]reflect.Value, 0, len(models))
+	slice := reflect.ValueOf(models)
+	for i := 0; i < slice.Len(); i++ {
+		if value, ok := addressableModel(slice.Index(i)); ok {
+			values = append(values, value)
+		}
+	}
+
+	return preload(ctx, th.collection.Database(), th.schema, values, relations)
+}
+
+// preload one model, the model is updated in place
+func (th *Collection[MODEL, ID]) preloadOne(ctx context.Context, model *MODEL, relations ...string) error {
+	if len(relations) == 0 {
+		return nil
+	}
+
+	value, ok := addressableModel(reflect.ValueOf(model).Elem())
+	if !ok {
+		return nil
+	}
+
+	return preload(ctx, th.collection.Database(), th.schema, []reflect.Value{value}, relations)
+}
+
+// pointer of model, nil pointer is skipped
+func addressableModel(value reflect.Value) (reflect.Value, bool) {
+	if value.Kind() == reflect.Ptr {
+		return value, !value.IsNil()
+	}
+	return value.Addr(), true
+}
+
+// relations with same prefix share one query
+func preload(ctx context.Context, db *mongo.Database, schema *entity.Entity, models []reflect.Value, relations []string) error {
+	if len(models) == 0 {
+		return nil
+	}
+
+	var names []string
+	nested := map[string][]string{}
+	for _, relation := range relations {
+		name, rest, _ := strings.Cut(relation, ".")
+		if _, ok := nested[name]; !ok {
+			names = append(names, name)
+			nested[name] = nil
+		}
+		if rest != "" {
+			nested[name] = append(nested[name], rest)
+		}
+	}
+
+	for _, name := range names {
+		relation, ok := schema.Relations[name]
+		if !ok {
+			return errors.WithStack(fmt.Errorf("%w: %s in model %s", errortype.ErrRelationDoesNotExists, name, schema.Name))
+		}
+
+		if err := preloadRelation(ctx, db, schema, relation, models, nested[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func preloadRelation(ctx context.Context, db *mongo.Database, schema *entity.Entity, relation *entity.Relation, models []reflect.Value, nested []string) error {
+	target, err := entity.GetOrParse(reflect.New(relation.TargetType).Interface())
+	if err != nil {
+		return err
+	}
+
+	// keys of each model used to match referenced models
+	keysOfModels := make([][]string, len(models))
+	var ids bson.A
+	seen := map[string]bool{}
+
+	var matchField *entity.EntityField
+	if relation.IsBackReference() {
+		matchField = target.LookUpField(relation.ForeignField)
+		if matchField == nil {
+			return errors.WithStack(fmt.Errorf("field %s not found in model %s", relation.ForeignField, target.Name))
+		}
+	} else {
+		matchField = target.IdField
+	}
+
+	for i, model := range models {
+		// back reference matches id of model
+		sourceField := relation.Field
+		if relation.IsBackReference() {
+			sourceField = schema.IdField
+		}
+
+		value, zero := sourceField.ValueOf(model)
+		if zero {
+			continue
+		}
+
+		for _, id := range flattenRefValue(value) {
+			key, err := refKey(id)
+			if err != nil {
+				return err
+			}
+			keysOfModels[i] = append(keysOfModels[i], key)
+			if !seen[key] {
+				seen[key] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cursor, err := db.Collection(target.Collection).Find(ctx, referencedQuery(target, matchField, ids))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	results := target.MakeSlice()
+	if err = cursor.All(ctx, results.Interface()); err != nil {
+		return errors.WithStack(err)
+	}
+	results = results.Elem()
+
+	referenced := make([]reflect.Value, results.Len())
+	for i := range referenced {
+		referenced[i] = results.Index(i)
+	}
+
+	if len(nested) > 0 {
+		if err = preload(ctx, db, target, referenced, nested); err != nil {
+			return err
+		}
+	}
+
+	// group referenced models by key
+	byKey := map[string][]reflect.Value{}
+	for _, value := range referenced {
+		key, _ := matchField.ValueOf(value)
+		for _, k := range flattenRefValue(key) {
+			s, err := refKey(k)
+			if err != nil {
+				return err
+			}
+			byKey[s] = append(byKey[s], value)
+		}
+	}
+
+	for i, model := range models {
+		var found []reflect.Value
+		for _, key := range keysOfModels[i] {
+			found = append(found, byKey[key]...)
+		}
+		if err = fillCompanion(model, relation, found); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// companion field is set to found models, the field is left untouched if nothing is found
+// referencedQuery query referenced models whose match field is in ids, models marked as deleted are not loaded
+func referencedQuery(target *entity.Entity, matchField *entity.EntityField, ids bson.A) any {
+	return applyDeletedScope(target.SoftDeleteField, deletedScopeExclude, bson.M{matchField.DBName: bson.M{"$in": ids}})
+}
+
+func fillCompanion(model reflect.Value, relation *entity.Relation, found []reflect.Value) error {
+	companion, err := reflect.Indirect(model).FieldByIndexErr(relation.Index)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	companionType := companion.Type()
+	if !relation.Many {
+		companion.Set(convertRefValue(found[0], companionType))
+		return nil
+	}
+
+	slice := reflect.MakeSlice(companionType, 0, len(found))
+	for _, value := range found {
+		slice = reflect.Append(slice, convertRefValue(value, companionType.Elem()))
+	}
+	companion.Set(slice)
+	return nil
+}
+
+// value is pointer of referenced model
+func convertRefValue(value reflect.Value, t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Ptr {
+		return value
+	}
+	return value.Elem()
+}
+
+// id field of reference can be a single id, a pointer of id or a slice of ids
+func flattenRefValue(value any) []any {
+	if value == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	// []byte is a single value
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i).Interface())
+		}
+		return values
+	}
+
+	return []any{v.Interface()}
+}
+
+// key by bson encoding, so that SObjectId and ObjectID holding same id are equal
+func refKey(value any) (string, error) {
+	t, data, err := bson.MarshalValue(value)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(rune(t)) + string(data), nil
+}
@@ -0,0 +1,98 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type PreloadUser struct {
+	Id     SObjectId       `bson:"_id"`
+	Orders []*PreloadOrder `bson:"-" jmgo:"refBy=UserId"`
+}
+
+type PreloadOrder struct {
+	Id     SObjectId    `bson:"_id"`
+	UserId SObjectId    `bson:"userId" jmgo:"ref=User"`
+	User   *PreloadUser `bson:"-"`
+}
+
+type PreloadComment struct {
+	Id        SObjectId  `bson:"_id"`
+	UserId    SObjectId  `bson:"userId"`
+	DeletedAt *time.Time `bson:"deletedAt" jmgo:"softDelete"`
+}
+
+func Test_Preload(t *testing.T) {
+	schema, err := entity.GetOrParse(&PreloadOrder{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	relation := schema.Relations["User"]
+	if relation == nil || relation.Field.DBName != "userId" || relation.Many || relation.TargetType != reflect.TypeOf(PreloadUser{}) {
+		t.Fatalf("unexpected relation %+v", relation)
+	}
+
+	userSchema, err := entity.GetOrParse(&PreloadUser{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	back := userSchema.Relations["Orders"]
+	if back == nil || !back.IsBackReference() || !back.Many || back.ForeignField != "UserId" {
+		t.Fatalf("unexpected back reference %+v", back)
+	}
+
+	// SObjectId and ObjectID holding same id share the key
+	id := NewSObjectId()
+	oid, _ := primitive.ObjectIDFromHex(string(id))
+	k1, err := refKey(id)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	k2, _ := refKey(oid)
+	if k1 != k2 {
+		t.Fatal("keys of same id should be equal")
+	}
+
+	if values := flattenRefValue([]SObjectId{id, id}); len(values) != 2 {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	user := &PreloadUser{Id: id}
+	order := &PreloadOrder{UserId: id}
+	err = fillCompanion(reflect.ValueOf(order), relation, []reflect.Value{reflect.ValueOf(user)})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if order.User != user {
+		t.Fatal("companion field should be filled")
+	}
+
+	err = fillCompanion(reflect.ValueOf(user), back, []reflect.Value{reflect.ValueOf(order), reflect.ValueOf(order)})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(user.Orders) != 2 || user.Orders[0] != order {
+		t.Fatalf("unexpected orders %v", user.Orders)
+	}
+
+	// referenced models marked as deleted are not loaded
+	commentSchema, err := entity.GetOrParse(&PreloadComment{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ids := bson.A{id}
+	query := referencedQuery(commentSchema, commentSchema.LookUpField("UserId"), ids)
+	if !reflect.DeepEqual(query, bson.M{"userId": bson.M{"$in": ids}, "deletedAt": nil}) {
+		t.Fatalf("unexpected query %v", query)
+	}
+	query = referencedQuery(schema, schema.IdField, ids)
+	if !reflect.DeepEqual(query, bson.M{"_id": bson.M{"$in": ids}}) {
+		t.Fatalf("unexpected query %v", query)
+	}
+}
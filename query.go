@@ -0,0 +1,118 @@
+package jmgo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+// Query fluent query builder of collection
+// field names used in Sort, Select and Omit are names defined in model
+type Query[MODEL any, ID any] struct {
+	collection *Collection[MODEL, ID]
+	filter     any
+	option     *FindOption
+}
+
+// Query create a query builder
+func (th *Collection[MODEL, ID]) Query() *Query[MODEL, ID] {
+	return &Query[MODEL, ID]{
+		collection: th,
+		filter:     bson.M{},
+		option:     Option(),
+	}
+}
+
+// Where filter type is any, you can use bson.M, bson.D, filter struct, id...
+func (th *Query[MODEL, ID]) Where(filter any) *Query[MODEL, ID] {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	th.filter = filter
+	return th
+}
+
+// Sort field with prefix '-' means sort from large to small, e.g. Sort("-CreatedAt", "Name")
+func (th *Query[MODEL, ID]) Sort(fields ...string) *Query[MODEL, ID] {
+	for _, field := range fields {
+		if strings.HasPrefix(field, "-") {
+			th.option.AddOrder(field[1:], false)
+		} else {
+			th.option.AddOrder(strings.TrimPrefix(field, "+"), true)
+		}
+	}
+	return th
+}
+
+// Select fields to select
+func (th *Query[MODEL, ID]) Select(fields ...string) *Query[MODEL, ID] {
+	th.option.AddIncludes(fields...)
+	return th
+}
+
+// Omit fields not to select
+func (th *Query[MODEL, ID]) Omit(fields ...string) *Query[MODEL, ID] {
+	th.option.AddExcludes(fields...)
+	return th
+}
+
+func (th *Query[MODEL, ID]) Skip(skip int) *Query[MODEL, ID] {
+	th.option.Offset(skip)
+	return th
+}
+
+func (th *Query[MODEL, ID]) Limit(limit int) *Query[MODEL, ID] {
+	th.option.Limit(limit)
+	return th
+}
+
+// Preload fill companion fields of relations, see FindOption.Preload
+func (th *Query[MODEL, ID]) Preload(relations ...string) *Query[MODEL, ID] {
+	th.option.Preload(relations...)
+	return th
+}
+
+// WithTotal total will be set when All is called
+func (th *Query[MODEL, ID]) WithTotal(total *int64) *Query[MODEL, ID] {
+	th.option.WithTotal(total)
+	return th
+}
+
+// Option merge other options, the configuration of query takes precedence
+func (th *Query[MODEL, ID]) Option(opts ...*FindOption) *Query[MODEL, ID] {
+	th.option = th.option.Merge(opts)
+	return th
+}
+
+func (th *Query[MODEL, ID]) All(ctx context.Context) ([]MODEL, error) {
+	return th.collection.FindByOption(ctx, th.filter, th.option)
+}
+
+func (th *Query[MODEL, ID]) One(ctx context.Context) (MODEL, error) {
+	return th.collection.FindOneByOption(ctx, th.filter, th.option)
+}
+
+func (th *Query[MODEL, ID]) Count(ctx context.Context) (int64, error) {
+	return th.collection.Count(ctx, th.filter)
+}
+
+func (th *Query[MODEL, ID]) Exists(ctx context.Context) (bool, error) {
+	return th.collection.Exists(ctx, th.filter)
+}
+
+// Iterate remember to close the iterator
+func (th *Query[MODEL, ID]) Iterate(ctx context.Context) (*Iter[MODEL], error) {
+	findOpts, err := th.option.makeFindOption(th.collection.schema)
+	if err != nil {
+		return nil, err
+	}
+	return th.collection.Iterate(ctx, th.filter, findOpts...)
+}
+
+func (th *Query[MODEL, ID]) ForEach(ctx context.Context, fn func(MODEL) error) error {
+	findOpts, err := th.option.makeFindOption(th.collection.schema)
+	if err != nil {
+		return err
+	}
+	return th.collection.ForEach(ctx, th.filter, fn, findOpts...)
+}
@@ -0,0 +1,57 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+func Test_Query(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+
+	query := col.Query().Where(bson.M{"name": "abc"}).Sort("-Age", "+Name", "HelloWorld").Select("Name", "Age").Skip(10).Limit(5)
+	if !reflect.DeepEqual(query.filter, bson.M{"name": "abc"}) {
+		t.Fatalf("unexpected filter %v", query.filter)
+	}
+
+	findOpts, err := query.option.makeFindOption(schema)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	opt := findOpts[0]
+	if *opt.Skip != 10 || *opt.Limit != 5 {
+		t.Fatalf("unexpected skip %d or limit %d", *opt.Skip, *opt.Limit)
+	}
+
+	sort := opt.Sort.(bson.D)
+	if !reflect.DeepEqual(sort, bson.D{{Key: "happy", Value: -1}, {Key: "name", Value: 1}, {Key: "helloWorld", Value: 1}}) {
+		t.Fatalf("unexpected sort %v", sort)
+	}
+
+	projection := opt.Projection.(bson.D)
+	if len(projection) != 2 || projection[0].Key != "name" || projection[1].Key != "happy" {
+		t.Fatalf("unexpected projection %v", projection)
+	}
+
+	// nil filter matches all documents
+	if query = col.Query().Where(nil); !reflect.DeepEqual(query.filter, bson.M{}) {
+		t.Fatalf("unexpected filter %v", query.filter)
+	}
+
+	// unknown field is reported when query runs
+	if _, err = col.Query().Omit("NotExists").option.makeFindOption(schema); err == nil {
+		t.Fatal("expect error for unknown field")
+	}
+
+	// configuration of query takes precedence over merged options
+	query = col.Query().Limit(5).Option(Option().Offset(3).Limit(10))
+	if query.option.limit != 5 || query.option.skip != 3 {
+		t.Fatalf("unexpected option %+v", query.option)
+	}
+}
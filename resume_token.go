@@ -0,0 +1,136 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultResumeTokenCollection collection of MongoResumeTokenStore
+const DefaultResumeTokenCollection = "_jmgo_resume_tokens"
+
+// ResumeTokenStore persist resume token of subscriptions by name, so that subscription continues after restart
+type ResumeTokenStore interface {
+	// Load return nil if there is no token of name
+	Load(ctx context.Context, name string) (bson.Raw, error)
+	Save(ctx context.Context, name string, token bson.Raw) error
+}
+
+// MemoryResumeTokenStore tokens are lost when process exits, it is useful to restart subscriptions in process and for tests
+type MemoryResumeTokenStore struct {
+	mutex  sync.Mutex
+	tokens map[string]bson.Raw
+}
+
+func NewMemoryResumeTokenStore() *MemoryResumeTokenStore {
+	return &MemoryResumeTokenStore{tokens: map[string]bson.Raw{}}
+}
+
+func (th *MemoryResumeTokenStore) Load(_ context.Context, name string) (bson.Raw, error) {
+	th.mutex.Lock()
+	defer th.mutex.Unlock()
+	return th.tokens[name], nil
+}
+
+func (th *MemoryResumeTokenStore) Save(_ context.Context, name string, token bson.Raw) error {
+	th.mutex.Lock()
+	defer th.mutex.Unlock()
+	th.tokens[name] = append(bson.Raw(nil), token...)
+	return nil
+}
+
+// FileResumeTokenStore each token is saved in a file named by escaped name in directory
+type FileResumeTokenStore struct {
+	dir string
+}
+
+func NewFileResumeTokenStore(dir string) *FileResumeTokenStore {
+	return &FileResumeTokenStore{dir: dir}
+}
+
+func (th *FileResumeTokenStore) Load(_ context.Context, name string) (bson.Raw, error) {
+	data, err := os.ReadFile(th.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	if err = bson.Raw(data).Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+// Save token is written to a temporary file and then renamed, so that the file is never partially written
+func (th *FileResumeTokenStore) Save(_ context.Context, name string, token bson.Raw) error {
+	if err := os.MkdirAll(th.dir, 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	file, err := os.CreateTemp(th.dir, ".token-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(token); err != nil {
+		_ = file.Close()
+		return errors.WithStack(err)
+	}
+	if err = file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(file.Name(), th.path(name)))
+}
+
+func (th *FileResumeTokenStore) path(name string) string {
+	return filepath.Join(th.dir, url.PathEscape(name)+".token")
+}
+
+// MongoResumeTokenStore tokens are saved as {_id: name, token, updatedAt}
+type MongoResumeTokenStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoResumeTokenStore DefaultResumeTokenCollection is used if collection is empty
+func NewMongoResumeTokenStore(db *Database, collection string) *MongoResumeTokenStore {
+	if collection == "" {
+		collection = DefaultResumeTokenCollection
+	}
+	return &MongoResumeTokenStore{collection: db.db.Collection(collection)}
+}
+
+func (th *MongoResumeTokenStore) Load(ctx context.Context, name string) (bson.Raw, error) {
+	var doc struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := th.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return doc.Token, nil
+}
+
+func (th *MongoResumeTokenStore) Save(ctx context.Context, name string, token bson.Raw) error {
+	_, err := th.collection.UpdateOne(ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{"token": token, "updatedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,67 @@
+package jmgo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func Test_ResumeTokenStore(t *testing.T) {
+	ctx := context.Background()
+	token, _ := bson.Marshal(bson.M{"_data": "8263"})
+
+	stores := map[string]ResumeTokenStore{
+		"memory": NewMemoryResumeTokenStore(),
+		"file":   NewFileResumeTokenStore(t.TempDir()),
+	}
+	for kind, store := range stores {
+		loaded, err := store.Load(ctx, "orders/sync")
+		if err != nil || loaded != nil {
+			t.Fatalf("%s: unexpected token %v %v", kind, loaded, err)
+		}
+
+		if err = store.Save(ctx, "orders/sync", token); err != nil {
+			t.Fatalf("%s: %+v", kind, err)
+		}
+
+		loaded, err = store.Load(ctx, "orders/sync")
+		if err != nil || string(loaded) != string(token) {
+			t.Fatalf("%s: unexpected token %v %v", kind, loaded, err)
+		}
+	}
+}
+
+func Test_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryResumeTokenStore()
+	s := &subscriber[*Test, SObjectId]{config: SubscribeConfig{Name: "test", Store: store, CheckpointEvents: 2, CheckpointInterval: time.Hour}}
+	if err := s.loadResumeToken(ctx); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	token, _ := bson.Marshal(bson.M{"_data": "1"})
+	s.resumeToken = token
+	s.pending = 1
+	s.checkpoint(false)
+	if saved, _ := store.Load(ctx, "test"); saved != nil {
+		t.Fatal("token should not be saved before checkpoint is due")
+	}
+
+	s.pending = 2
+	s.checkpoint(false)
+	if saved, _ := store.Load(ctx, "test"); string(saved) != string(token) || s.pending != 0 {
+		t.Fatal("token should be saved after 2 events")
+	}
+
+	// token expired, start from fallback time
+	fallback := &primitive.Timestamp{T: 100}
+	s.config.FallbackStartAtOperationTime = fallback
+	s.resumeToken = nil
+	s.expired = true
+	opt := s.streamOptions()
+	if opt.ResumeAfter != nil || opt.StartAtOperationTime != fallback {
+		t.Fatalf("unexpected options %+v", opt)
+	}
+}
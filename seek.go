@@ -0,0 +1,238 @@
+package jmgo
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+)
+
+// SeekPage keyset pagination
+// empty token means the first page
+type SeekPage interface {
+	GetPageToken() string
+
+	GetLength() int64
+}
+
+// seekToken values of sort keys of the document at the edge of page, keys and directions of sort
+// are kept to reject token created by another sort
+type seekToken struct {
+	Keys       []string `bson:"k"`
+	Directions []int    `bson:"d"`
+	Values     bson.A   `bson:"v"`
+	// Prev fetch documents before the edge document
+	Prev bool `bson:"p,omitempty"`
+}
+
+func encodeSeekToken(token *seekToken) (string, error) {
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSeekToken(s string, sort bson.D) (*seekToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.WithStack(fmt.Errorf("%w: %s", errortype.ErrInvalidPageToken, err.Error()))
+	}
+
+	var token seekToken
+	if err := bson.Unmarshal(data, &token); err != nil {
+		return nil, errors.WithStack(fmt.Errorf("%w: %s", errortype.ErrInvalidPageToken, err.Error()))
+	}
+
+	// token must be created by the same sort
+	if len(token.Keys) != len(sort) || len(token.Directions) != len(sort) || len(token.Values) != len(sort) {
+		return nil, errors.WithStack(fmt.Errorf("%w: sort not match", errortype.ErrInvalidPageToken))
+	}
+	for i, e := range sort {
+		if token.Keys[i] != e.Key || token.Directions[i] != e.Value {
+			return nil, errors.WithStack(fmt.Errorf("%w: sort not match", errortype.ErrInvalidPageToken))
+		}
+	}
+
+	return &token, nil
+}
+
+// makeSeekPredicate build range predicate for multi-field sorts, e.g. sort by a asc, b desc:
+// {$or: [{a: {$gt: va}}, {a: va, b: {$lt: vb}}]}
+func makeSeekPredicate(sort bson.D, token *seekToken) bson.M {
+	or := make(bson.A, 0, len(sort))
+	for i, e := range sort {
+		cond := bson.M{}
+		for j := 0; j < i; j++ {
+			cond[sort[j].Key] = token.Values[j]
+		}
+
+		// move forward if sort direction is asc and fetch next page
+		forward := e.Value == 1
+		if token.Prev {
+			forward = !forward
+		}
+		if forward {
+			cond[e.Key] = bson.M{"$gt": token.Values[i]}
+		} else {
+			cond[e.Key] = bson.M{"$lt": token.Values[i]}
+		}
+		or = append(or, cond)
+	}
+
+	return bson.M{"$or": or}
+}
+
+// FindSeekPage keyset pagination, sort fields are specified by AddOrder of option, and id field is always
+// appended as the last sort field to make order stable, skip and limit of option will be ignored.
+// return empty token if there is no next or previous page
+func (th *Collection[MODEL, ID]) FindSeekPage(ctx context.Context, page SeekPage, filter any, opts ...*FindOption) ([]MODEL, string, string, error) {
+
+	length := page.GetLength()
+	if length <= 0 {
+		return nil, "", "", errors.WithStack(fmt.Errorf("%w: %d", errortype.ErrInvalidPageLength, length))
+	}
+
+	option := Merge(opts)
+	if option == nil {
+		option = Option()
+	}
+
+	findOpts, err := option.makeFindOption(th.schema)
+	if err != nil {
+		return nil, "", "", err
+	}
+	findOpt := findOpts[0]
+	findOpt.Skip = nil
+
+	// sort fields with id field as tie-breaker
+	sort, err := option.makeSort(th.schema, option.sorts)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if !th.containsSortKey(sort, th.schema.IdDBName()) {
+		sort = append(sort, primitive.E{Key: th.schema.IdDBName(), Value: 1})
+	}
+
+	query, _, err := th.convertFilter(filter)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	var token *seekToken
+	if page.GetPageToken() != "" {
+		token, err = decodeSeekToken(page.GetPageToken(), sort)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		predicate := makeSeekPredicate(sort, token)
+		if isEmptyQuery(query) {
+			query = predicate
+		} else {
+			query = bson.M{"$and": bson.A{query, predicate}}
+		}
+	}
+
+	// reverse sort to fetch previous page
+	prev := token != nil && token.Prev
+	querySort := sort
+	if prev {
+		querySort = make(bson.D, len(sort))
+		for i, e := range sort {
+			querySort[i] = primitive.E{Key: e.Key, Value: -e.Value.(int)}
+		}
+	}
+
+	// fetch one more to know whether there are more documents
+	findOpt.SetSort(querySort).SetLimit(length + 1)
+	items, err := th.Find(ctx, query, findOpt)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	more := int64(len(items)) > length
+	if more {
+		items = items[:length]
+	}
+
+	if prev {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if len(items) == 0 {
+		return items, "", "", nil
+	}
+
+	if err = th.Preload(ctx, items, option.preloads...); err != nil {
+		return nil, "", "", err
+	}
+
+	// there are documents after the page if more documents are found forward or we came from the next page
+	var next, prevToken string
+	if more || prev {
+		next, err = th.makeSeekToken(sort, items[len(items)-1], false)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+	// there are documents before the page if more documents are found backward or we came from the previous page
+	if (prev && more) || (!prev && token != nil) {
+		prevToken, err = th.makeSeekToken(sort, items[0], true)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	return items, next, prevToken, nil
+}
+
+func (th *Collection[MODEL, ID]) containsSortKey(sort bson.D, key string) bool {
+	for _, e := range sort {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (th *Collection[MODEL, ID]) makeSeekToken(sort bson.D, model MODEL, prev bool) (string, error) {
+	value := reflect.ValueOf(model)
+	token := &seekToken{
+		Keys:       make([]string, 0, len(sort)),
+		Directions: make([]int, 0, len(sort)),
+		Values:     make(bson.A, 0, len(sort)),
+		Prev:       prev,
+	}
+	for _, e := range sort {
+		field, err := th.mustSchemaField(e.Key)
+		if err != nil {
+			return "", err
+		}
+		v, _ := field.ValueOf(value)
+		token.Keys = append(token.Keys, e.Key)
+		token.Directions = append(token.Directions, e.Value.(int))
+		token.Values = append(token.Values, v)
+	}
+
+	return encodeSeekToken(token)
+}
+
+// isEmptyQuery whether query has no condition, count of converted filter is 0 for id filters, so query itself is checked
+func isEmptyQuery(query any) bool {
+	switch q := query.(type) {
+	case nil:
+		return true
+	case bson.M:
+		return len(q) == 0
+	case bson.D:
+		return len(q) == 0
+	}
+	return false
+}
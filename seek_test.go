@@ -0,0 +1,95 @@
+package jmgo
+
+import (
+	"context"
+	"errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+)
+
+func Test_SeekToken(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+
+	id := NewSObjectId()
+	sort := bson.D{{Key: "happy", Value: -1}, {Key: "_id", Value: 1}}
+	s, err := col.makeSeekToken(sort, &Test{Id: id, Age: 18}, false)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	token, err := decodeSeekToken(s, sort)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(string(id))
+	predicate := makeSeekPredicate(sort, token)
+	expected := bson.M{"$or": bson.A{
+		bson.M{"happy": bson.M{"$lt": int32(18)}},
+		bson.M{"happy": int32(18), "_id": bson.M{"$gt": oid}},
+	}}
+	if !reflect.DeepEqual(predicate, expected) {
+		t.Fatalf("unexpected predicate %v", predicate)
+	}
+
+	_, err = decodeSeekToken(s, bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	if err == nil {
+		t.Fatal("expect error for token created by another sort")
+	}
+
+	_, err = decodeSeekToken(s, bson.D{{Key: "happy", Value: 1}, {Key: "_id", Value: 1}})
+	if !errors.Is(err, errortype.ErrInvalidPageToken) {
+		t.Fatalf("expect error for token created by another direction, got %v", err)
+	}
+}
+
+type seekPage struct {
+	token  string
+	length int64
+}
+
+func (p seekPage) GetPageToken() string {
+	return p.token
+}
+
+func (p seekPage) GetLength() int64 {
+	return p.length
+}
+
+func Test_FindSeekPageLength(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+
+	for _, length := range []int64{0, -1} {
+		_, _, _, err = col.FindSeekPage(context.Background(), seekPage{length: length}, bson.M{})
+		if !errors.Is(err, errortype.ErrInvalidPageLength) {
+			t.Fatalf("expect error for length %d, got %v", length, err)
+		}
+	}
+}
+
+func Test_IsEmptyQuery(t *testing.T) {
+	for _, query := range []any{nil, bson.M{}, bson.D{}} {
+		if !isEmptyQuery(query) {
+			t.Fatalf("%v should be empty", query)
+		}
+	}
+
+	// id filters are converted with count 0, but they are not empty
+	for _, query := range []any{bson.M{"_id": "1"}, bson.D{{Key: "_id", Value: bson.M{"$in": bson.A{"1", "2"}}}}} {
+		if isEmptyQuery(query) {
+			t.Fatalf("%v should not be empty", query)
+		}
+	}
+}
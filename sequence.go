@@ -0,0 +1,196 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/now"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SequencePeriod the sequence restarts from 1 in each period
+type SequencePeriod uint8
+
+const (
+	// SequencePeriodNone never restarts
+	SequencePeriodNone SequencePeriod = iota
+	SequencePeriodDaily
+	SequencePeriodMonthly
+	SequencePeriodYearly
+)
+
+type SequenceConfig struct {
+	// Name key of counter
+	Name string
+	// BlockSize numbers allocated in one round-trip to improve throughput,
+	// numbers not used are lost when process exits, default is 1
+	BlockSize int64
+	// Period the sequence restarts in each period
+	Period SequencePeriod
+	// Format template of NextString, supported placeholders are {yyyy}, {yy}, {MM}, {dd}, {HH} and {seq},
+	// width of zero padded sequence can be specified by {seq:6}, e.g. "ORD-{yyyy}-{seq:6}" formats ORD-2026-000123
+	Format string
+	// Location of period and date placeholders, time.Local is used if it is nil
+	Location *time.Location
+	// Now clock of period, time.Now is used if it is nil
+	Now func() time.Time
+	// Collection to store counters, DefaultCounterCollection is used if it is empty
+	Collection string
+}
+
+// Sequence generate increasing numbers stored in counter collection by atomic $inc
+type Sequence struct {
+	collection *mongo.Collection
+	config     SequenceConfig
+	mutex      sync.Mutex
+	// key of period that the allocated block belongs to
+	period string
+	// allocated block is [next, end]
+	next int64
+	end  int64
+}
+
+func NewSequence(database *Database, config SequenceConfig) *Sequence {
+	if config.BlockSize <= 0 {
+		config.BlockSize = 1
+	}
+	if config.Collection == "" {
+		config.Collection = DefaultCounterCollection
+	}
+
+	return &Sequence{
+		collection: database.db.Collection(config.Collection),
+		config:     config,
+	}
+}
+
+// Next next number in current period
+func (th *Sequence) Next(ctx context.Context) (int64, error) {
+	value, _, err := th.take(ctx)
+	return value, err
+}
+
+// NextString next number formatted by the template, number is returned if no template is specified
+func (th *Sequence) NextString(ctx context.Context) (string, error) {
+	value, t, err := th.take(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if th.config.Format == "" {
+		return strconv.FormatInt(value, 10), nil
+	}
+
+	return formatSequence(th.config.Format, value, t), nil
+}
+
+// NextId the sequence can be used as IdGenerator, formatted string is generated if template is specified
+func (th *Sequence) NextId(ctx context.Context) (any, error) {
+	if th.config.Format != "" {
+		return th.NextString(ctx)
+	}
+	return th.Next(ctx)
+}
+
+func (th *Sequence) take(ctx context.Context) (int64, time.Time, error) {
+	th.mutex.Lock()
+	defer th.mutex.Unlock()
+
+	t := th.now()
+	period := th.periodKey(t)
+
+	// allocate a new block if block is used up or period changes
+	if period != th.period || th.next > th.end {
+		end, err := th.allocate(ctx, period)
+		if err != nil {
+			return 0, t, err
+		}
+		th.period = period
+		th.next = end - th.config.BlockSize + 1
+		th.end = end
+	}
+
+	value := th.next
+	th.next++
+
+	return value, t, nil
+}
+
+// allocate return the end of allocated block
+func (th *Sequence) allocate(ctx context.Context, period string) (int64, error) {
+	var counter struct {
+		Value int64 `bson:"value"`
+	}
+
+	key := th.config.Name
+	if period != "" {
+		key = key + ":" + period
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := th.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, bson.M{"$inc": bson.M{"value": th.config.BlockSize}}, opts).Decode(&counter)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return counter.Value, nil
+}
+
+func (th *Sequence) now() time.Time {
+	var t time.Time
+	if th.config.Now != nil {
+		t = th.config.Now()
+	} else {
+		t = time.Now()
+	}
+
+	if th.config.Location != nil {
+		return t.In(th.config.Location)
+	}
+	return t
+}
+
+// periodKey key of the period which t belongs to
+func (th *Sequence) periodKey(t time.Time) string {
+	switch th.config.Period {
+	case SequencePeriodDaily:
+		return now.With(t).BeginningOfDay().Format("20060102")
+	case SequencePeriodMonthly:
+		return now.With(t).BeginningOfMonth().Format("200601")
+	case SequencePeriodYearly:
+		return now.With(t).BeginningOfYear().Format("2006")
+	default:
+		return ""
+	}
+}
+
+var sequencePlaceholder = regexp.MustCompile(`\{(yyyy|yy|MM|dd|HH|seq)(?::(\d+))?}`)
+
+func formatSequence(format string, value int64, t time.Time) string {
+	return sequencePlaceholder.ReplaceAllStringFunc(format, func(placeholder string) string {
+		match := sequencePlaceholder.FindStringSubmatch(placeholder)
+		switch match[1] {
+		case "yyyy":
+			return t.Format("2006")
+		case "yy":
+			return t.Format("06")
+		case "MM":
+			return t.Format("01")
+		case "dd":
+			return t.Format("02")
+		case "HH":
+			return t.Format("15")
+		default:
+			if match[2] != "" {
+				return fmt.Sprintf("%0"+match[2]+"d", value)
+			}
+			return strconv.FormatInt(value, 10)
+		}
+	})
+}
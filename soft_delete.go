@@ -0,0 +1,154 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"time"
+)
+
+// deletedScope which documents are visible when model has soft delete field
+type deletedScope uint8
+
+const (
+	// deletedScopeExclude documents marked as deleted are excluded
+	deletedScopeExclude deletedScope = iota
+	// deletedScopeInclude all documents are included
+	deletedScopeInclude
+	// deletedScopeOnly only documents marked as deleted are included
+	deletedScopeOnly
+)
+
+// WithDeleted return a collection whose operations include documents marked as deleted
+func (th *Collection[MODEL, ID]) WithDeleted() *Collection[MODEL, ID] {
+	return th.withDeletedScope(deletedScopeInclude)
+}
+
+// OnlyDeleted return a collection whose operations only include documents marked as deleted
+func (th *Collection[MODEL, ID]) OnlyDeleted() *Collection[MODEL, ID] {
+	return th.withDeletedScope(deletedScopeOnly)
+}
+
+func (th *Collection[MODEL, ID]) withDeletedScope(scope deletedScope) *Collection[MODEL, ID] {
+	col := *th
+	col.deletedScope = scope
+	return &col
+}
+
+// Restore restore documents marked as deleted, return number of restored documents
+func (th *Collection[MODEL, ID]) Restore(ctx context.Context, filter any) (int64, error) {
+
+	field := th.schema.SoftDeleteField
+	if field == nil {
+		return 0, errors.WithStack(errortype.ErrSoftDeleteFieldDoesNotExists)
+	}
+
+	query, err := th.OnlyDeleted().mustConvertFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := th.collection.UpdateMany(ctx, query, bson.M{
+		"$set": bson.M{field.DBName: reflect.Zero(field.FieldType).Interface()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// HardDelete delete documents physically, documents marked as deleted are included unless OnlyDeleted is used.
+// rules declared by jmgo:"onDelete" are applied as Delete
+func (th *Collection[MODEL, ID]) HardDelete(ctx context.Context, filter any) (bool, error) {
+
+	col := th
+	if th.deletedScope == deletedScopeExclude {
+		col = th.WithDeleted()
+	}
+
+	query, err := col.mustConvertFilter(filter)
+	if err != nil {
+		return false, err
+	}
+
+	if len(deleteRulesOf(th.schema)) > 0 {
+		count, err := th.deleteWithRules(ctx, query, true, true)
+		return count > 0, err
+	}
+
+	result, err := th.collection.DeleteMany(ctx, query)
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// makeDeletedUpdate bool field is set to true, integer field is set to unix milli and time field is set to now
+func (th *Collection[MODEL, ID]) makeDeletedUpdate() bson.M {
+	return makeDeletedUpdate(th.schema.SoftDeleteField, th.now())
+}
+
+func makeDeletedUpdate(field *entity.EntityField, now time.Time) bson.M {
+	fieldType := entity.Indirect(field.FieldType)
+
+	var value any
+	if entity.IsBoolType(fieldType) {
+		value = reflect.ValueOf(true).Convert(fieldType).Interface()
+	} else {
+		value = timeValueOf(fieldType, now)
+	}
+
+	return bson.M{"$set": bson.M{field.DBName: value}}
+}
+
+// applyDeletedScope append condition of soft delete field to query
+func (th *Collection[MODEL, ID]) applyDeletedScope(query any) any {
+	return applyDeletedScope(th.schema.SoftDeleteField, th.deletedScope, query)
+}
+
+func applyDeletedScope(field *entity.EntityField, scope deletedScope, query any) any {
+	if field == nil || scope == deletedScopeInclude {
+		return query
+	}
+
+	// missing, null or zero value means not deleted, zero of element type is used for pointer,
+	// except pointer of time which is only null when not deleted
+	var condition any
+	fieldType := field.FieldType
+	nilOnly := fieldType.Kind() == reflect.Ptr && entity.IsTimeType(fieldType)
+	zero := reflect.Zero(entity.Indirect(fieldType)).Interface()
+	if scope == deletedScopeOnly {
+		if nilOnly {
+			condition = bson.M{"$ne": nil}
+		} else {
+			condition = bson.M{"$nin": bson.A{nil, zero}}
+		}
+	} else {
+		if nilOnly {
+			condition = nil
+		} else {
+			condition = bson.M{"$in": bson.A{nil, zero}}
+		}
+	}
+
+	// the condition specified by caller takes precedence
+	if hasCondition(query, field.DBName) {
+		return query
+	}
+
+	return addCondition(query, field.DBName, condition)
+}
+
+// timeValueOf convert time to value of field type, integer field is set to unix milli
+func timeValueOf(fieldType reflect.Type, t time.Time) any {
+	fieldType = entity.Indirect(fieldType)
+	if entity.IsIntegerType(fieldType) {
+		return reflect.ValueOf(t.UnixMilli()).Convert(fieldType).Interface()
+	}
+	return reflect.ValueOf(t).Convert(fieldType).Interface()
+}
@@ -0,0 +1,182 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ApplyDeletedScope(t *testing.T) {
+	type PointerModel struct {
+		Id        SObjectId  `bson:"_id"`
+		DeletedAt *time.Time `bson:"deletedAt" jmgo:"softDelete"`
+	}
+	type BoolModel struct {
+		Id      SObjectId `bson:"_id"`
+		Deleted bool      `bson:"deleted" jmgo:"softDelete"`
+	}
+	type IntModel struct {
+		Id        SObjectId `bson:"_id"`
+		DeletedAt int64     `bson:"deletedAt" jmgo:"softDelete"`
+	}
+	type BoolPointerModel struct {
+		Id      SObjectId `bson:"_id"`
+		Deleted *bool     `bson:"deleted" jmgo:"softDelete"`
+	}
+	type IntPointerModel struct {
+		Id        SObjectId `bson:"_id"`
+		DeletedAt *int64    `bson:"deletedAt" jmgo:"softDelete"`
+	}
+
+	fieldOf := func(model any) *entity.EntityField {
+		schema, err := entity.GetOrParse(model)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		return schema.SoftDeleteField
+	}
+	pointerField := fieldOf(&PointerModel{})
+	boolField := fieldOf(&BoolModel{})
+	intField := fieldOf(&IntModel{})
+	boolPointerField := fieldOf(&BoolPointerModel{})
+	intPointerField := fieldOf(&IntPointerModel{})
+
+	tests := []struct {
+		name     string
+		field    *entity.EntityField
+		scope    deletedScope
+		query    any
+		expected any
+	}{
+		{
+			name:     "without soft delete field",
+			field:    nil,
+			scope:    deletedScopeExclude,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a"},
+		},
+		{
+			name:     "exclude pointer",
+			field:    pointerField,
+			scope:    deletedScopeExclude,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a", "deletedAt": nil},
+		},
+		{
+			name:     "only pointer",
+			field:    pointerField,
+			scope:    deletedScopeOnly,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a", "deletedAt": bson.M{"$ne": nil}},
+		},
+		{
+			name:     "include pointer",
+			field:    pointerField,
+			scope:    deletedScopeInclude,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a"},
+		},
+		{
+			name:     "exclude bool",
+			field:    boolField,
+			scope:    deletedScopeExclude,
+			query:    bson.M{},
+			expected: bson.M{"deleted": bson.M{"$in": bson.A{nil, false}}},
+		},
+		{
+			name:     "only bool",
+			field:    boolField,
+			scope:    deletedScopeOnly,
+			query:    bson.M{},
+			expected: bson.M{"deleted": bson.M{"$nin": bson.A{nil, false}}},
+		},
+		{
+			name:     "exclude bool pointer",
+			field:    boolPointerField,
+			scope:    deletedScopeExclude,
+			query:    bson.M{},
+			expected: bson.M{"deleted": bson.M{"$in": bson.A{nil, false}}},
+		},
+		{
+			name:     "only bool pointer",
+			field:    boolPointerField,
+			scope:    deletedScopeOnly,
+			query:    bson.M{},
+			expected: bson.M{"deleted": bson.M{"$nin": bson.A{nil, false}}},
+		},
+		{
+			name:     "exclude int pointer",
+			field:    intPointerField,
+			scope:    deletedScopeExclude,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a", "deletedAt": bson.M{"$in": bson.A{nil, int64(0)}}},
+		},
+		{
+			name:     "only int pointer",
+			field:    intPointerField,
+			scope:    deletedScopeOnly,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a", "deletedAt": bson.M{"$nin": bson.A{nil, int64(0)}}},
+		},
+		{
+			name:     "exclude int",
+			field:    intField,
+			scope:    deletedScopeExclude,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a", "deletedAt": bson.M{"$in": bson.A{nil, int64(0)}}},
+		},
+		{
+			name:     "include int",
+			field:    intField,
+			scope:    deletedScopeInclude,
+			query:    bson.M{"name": "a"},
+			expected: bson.M{"name": "a"},
+		},
+		{
+			name:     "condition of caller takes precedence",
+			field:    intField,
+			scope:    deletedScopeExclude,
+			query:    bson.M{"deletedAt": bson.M{"$gt": 100}},
+			expected: bson.M{"deletedAt": bson.M{"$gt": 100}},
+		},
+		{
+			name:     "condition of caller in bson.D takes precedence",
+			field:    pointerField,
+			scope:    deletedScopeOnly,
+			query:    bson.D{{Key: "deletedAt", Value: nil}},
+			expected: bson.D{{Key: "deletedAt", Value: nil}},
+		},
+		{
+			name:     "bson.D",
+			field:    boolField,
+			scope:    deletedScopeExclude,
+			query:    bson.D{{Key: "name", Value: "a"}},
+			expected: bson.D{{Key: "name", Value: "a"}, {Key: "deleted", Value: bson.M{"$in": bson.A{nil, false}}}},
+		},
+		{
+			name:     "other query",
+			field:    pointerField,
+			scope:    deletedScopeExclude,
+			query:    bson.A{},
+			expected: bson.M{"$and": bson.A{bson.A{}, bson.M{"deletedAt": nil}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := applyDeletedScope(test.field, test.scope, test.query)
+			if !reflect.DeepEqual(query, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, query)
+			}
+		})
+	}
+
+	// query of caller is not modified
+	query := bson.M{"name": "a"}
+	applyDeletedScope(pointerField, deletedScopeExclude, query)
+	if len(query) != 1 {
+		t.Fatalf("query of caller is modified %v", query)
+	}
+}
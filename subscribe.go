@@ -0,0 +1,388 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// OperationType type of change event
+type OperationType string
+
+const (
+	OperationInsert       OperationType = "insert"
+	OperationUpdate       OperationType = "update"
+	OperationReplace      OperationType = "replace"
+	OperationDelete       OperationType = "delete"
+	OperationDrop         OperationType = "drop"
+	OperationRename       OperationType = "rename"
+	OperationDropDatabase OperationType = "dropDatabase"
+	// OperationInvalidate the stream is invalidated, subscription continues after it
+	OperationInvalidate OperationType = "invalidate"
+)
+
+// UpdateDescription fields changed by update
+type UpdateDescription struct {
+	UpdatedFields   bson.M   `bson:"updatedFields"`
+	RemovedFields   []string `bson:"removedFields"`
+	TruncatedArrays []struct {
+		Field   string `bson:"field"`
+		NewSize int32  `bson:"newSize"`
+	} `bson:"truncatedArrays"`
+}
+
+// ChangeEvent decoded change event, FullDocument and FullDocumentBeforeChange are zero
+// unless they are requested by SetFullDocument and SetFullDocumentBeforeChange of options
+type ChangeEvent[MODEL any, ID any] struct {
+	OperationType OperationType
+	// Collection name of collection changed, it is useful for subscription of database
+	Collection string
+	// DocumentKey id of changed document
+	DocumentKey              ID
+	FullDocument             MODEL
+	FullDocumentBeforeChange MODEL
+	UpdateDescription        *UpdateDescription
+	ClusterTime              primitive.Timestamp
+	ResumeToken              bson.Raw
+	// Raw the whole event
+	Raw bson.Raw
+}
+
+type rawChangeEvent struct {
+	OperationType OperationType `bson:"operationType"`
+	Namespace     struct {
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey              bson.Raw            `bson:"documentKey"`
+	FullDocument             bson.Raw            `bson:"fullDocument"`
+	FullDocumentBeforeChange bson.Raw            `bson:"fullDocumentBeforeChange"`
+	UpdateDescription        *UpdateDescription  `bson:"updateDescription"`
+	ClusterTime              primitive.Timestamp `bson:"clusterTime"`
+}
+
+type SubscribeConfig struct {
+	// ChangeStream options of change stream, resume options are overridden once an event is handled
+	ChangeStream *options.ChangeStreamOptions
+	// OnError called when change stream fails or handler returns error, DefaultLogger is used if it is nil
+	OnError func(err error)
+	// MinBackoff delay before the first retry, default is 1 second
+	MinBackoff time.Duration
+	// MaxBackoff delay doubles on each consecutive failure up to it, default is 1 minute
+	MaxBackoff time.Duration
+	// Name key of resume token in Store, it is required if Store is specified and must be unique among subscriptions
+	Name string
+	// Store resume token is loaded when subscription starts and saved on checkpoint
+	Store ResumeTokenStore
+	// CheckpointEvents save token after the number of events are handled
+	CheckpointEvents int
+	// CheckpointInterval save token if the duration has passed since last save when an event is handled,
+	// token is saved after every event if neither CheckpointEvents nor CheckpointInterval is specified
+	CheckpointInterval time.Duration
+	// FallbackStartAtOperationTime where to start when resume token has expired from oplog, events after it are lost.
+	// the stream starts from now if it is nil
+	FallbackStartAtOperationTime *primitive.Timestamp
+}
+
+// Subscribe handle change events of collection until context is canceled.
+// the stream is reopened from the last handled event with exponential backoff when it fails or handler returns error,
+// so an event is handled again if handler returns error. pipeline is passed to change stream as is, e.g. mongo.Pipeline
+func (th *Collection[MODEL, ID]) Subscribe(ctx context.Context, pipeline any, handler func(ctx context.Context, event ChangeEvent[MODEL, ID]) error, config SubscribeConfig) error {
+	return subscribe(ctx, th.collection.Name(), th.collection.Watch, pipeline, handler, config)
+}
+
+// Subscribe handle change events of all collections in database as Collection.Subscribe,
+// documents are not decoded, and Collection of event tells which collection is changed
+func (th *Database) Subscribe(ctx context.Context, pipeline any, handler func(ctx context.Context, event ChangeEvent[bson.Raw, any]) error, config SubscribeConfig) error {
+	return subscribe(ctx, th.db.Name(), th.db.Watch, pipeline, handler, config)
+}
+
+func subscribe[MODEL any, ID any](ctx context.Context, name string, open streamOpener, pipeline any, handler func(ctx context.Context, event ChangeEvent[MODEL, ID]) error, config SubscribeConfig) error {
+	// subscriptions without name would overwrite tokens of each other
+	if config.Store != nil && config.Name == "" {
+		return errors.New("name of subscription is required to save resume token in store")
+	}
+
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+
+	s := &subscriber[MODEL, ID]{
+		name:     name,
+		open:     open,
+		pipeline: pipeline,
+		handler:  handler,
+		config:   config,
+	}
+	return s.run(ctx)
+}
+
+// streamOpener Watch of mongo.Collection or mongo.Database
+type streamOpener func(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+
+// subscriber state of a subscription, it is only accessed by the goroutine running Subscribe
+type subscriber[MODEL any, ID any] struct {
+	// name of collection or database, used in log
+	name     string
+	open     streamOpener
+	pipeline any
+	handler  func(ctx context.Context, event ChangeEvent[MODEL, ID]) error
+	config   SubscribeConfig
+	// resumeToken token of the last handled event, or of the last empty batch
+	resumeToken bson.Raw
+	// invalidated stream can only be resumed by startAfter
+	invalidated bool
+	// expired resume token has expired from oplog
+	expired bool
+	// fromNow fallback start time has also expired from oplog, so the stream starts from now
+	fromNow bool
+	// pending number of handled events whose token is not saved
+	pending int
+	// lastCheckpoint time when token was saved
+	lastCheckpoint time.Time
+}
+
+func (th *subscriber[MODEL, ID]) run(ctx context.Context) error {
+	minBackoff := th.config.MinBackoff
+	if minBackoff <= 0 {
+		minBackoff = time.Second
+	}
+	maxBackoff := th.config.MaxBackoff
+	if maxBackoff < minBackoff {
+		maxBackoff = time.Minute
+		if maxBackoff < minBackoff {
+			maxBackoff = minBackoff
+		}
+	}
+
+	if err := th.loadResumeToken(ctx); err != nil {
+		return err
+	}
+
+	backoff := minBackoff
+	for {
+		handled, err := th.watch(ctx)
+		th.checkpoint(true)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// start from fallback time immediately, and then from now if fallback time has expired too
+		if isResumeTokenExpired(err) && !th.expired {
+			th.reportError(errors.WithMessage(err, "resume token expired, events may be lost"))
+			th.resumeToken = nil
+			th.expired = true
+			continue
+		}
+		if isResumeTokenExpired(err) && !th.fromNow {
+			th.reportError(errors.WithMessage(err, "fallback start time expired too, start from now, events until now are lost"))
+			th.fromNow = true
+			continue
+		}
+
+		// failure after some events is not consecutive
+		if handled {
+			backoff = minBackoff
+		}
+		if err != nil {
+			th.reportError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// watch open change stream and handle events until it fails, return whether any event is handled
+func (th *subscriber[MODEL, ID]) watch(ctx context.Context) (bool, error) {
+	stream, err := th.open(ctx, th.pipeline, th.streamOptions())
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer func() {
+		_ = stream.Close(context.Background())
+	}()
+
+	// token of empty batch is cached by stream, so the stream is reopened from here
+	// instead of from now if it fails before any event is handled
+	th.advance(stream.ResumeToken())
+
+	handled := false
+	for stream.Next(ctx) {
+		event, err := th.decode(stream.Current, stream.ResumeToken())
+		if err != nil {
+			return handled, err
+		}
+
+		if err = th.handler(ctx, event); err != nil {
+			return handled, errors.WithMessagef(err, "handle %s event of %v", event.OperationType, event.DocumentKey)
+		}
+
+		handled = true
+		th.advance(event.ResumeToken)
+		th.invalidated = event.OperationType == OperationInvalidate
+		th.pending++
+		th.checkpoint(false)
+		if th.invalidated {
+			// stream is closed by server after invalidate event
+			return handled, nil
+		}
+	}
+
+	// all events returned by stream have been handled
+	th.advance(stream.ResumeToken())
+	return handled, errors.WithStack(stream.Err())
+}
+
+// advance resume from token, it is ignored if it is nil
+func (th *subscriber[MODEL, ID]) advance(token bson.Raw) {
+	if token == nil {
+		return
+	}
+	th.resumeToken = token
+	th.expired = false
+	th.fromNow = false
+	th.invalidated = false
+}
+
+// streamOptions resume after the last handled event
+func (th *subscriber[MODEL, ID]) streamOptions() *options.ChangeStreamOptions {
+	opt := options.ChangeStream()
+	if th.config.ChangeStream != nil {
+		copied := *th.config.ChangeStream
+		opt = &copied
+	}
+
+	if th.resumeToken != nil || th.expired {
+		opt.SetResumeAfter(nil)
+		opt.SetStartAfter(nil)
+		opt.SetStartAtOperationTime(nil)
+		switch {
+		case th.resumeToken == nil && !th.fromNow:
+			opt.SetStartAtOperationTime(th.config.FallbackStartAtOperationTime)
+		case th.resumeToken == nil:
+			// start from now
+		case th.invalidated:
+			opt.SetStartAfter(th.resumeToken)
+		default:
+			opt.SetResumeAfter(th.resumeToken)
+		}
+	}
+
+	return opt
+}
+
+func (th *subscriber[MODEL, ID]) decode(raw bson.Raw, resumeToken bson.Raw) (ChangeEvent[MODEL, ID], error) {
+	event := ChangeEvent[MODEL, ID]{
+		Raw:         raw,
+		ResumeToken: resumeToken,
+	}
+
+	var r rawChangeEvent
+	if err := bson.Unmarshal(raw, &r); err != nil {
+		return event, errors.WithStack(err)
+	}
+
+	event.OperationType = r.OperationType
+	event.Collection = r.Namespace.Collection
+	event.UpdateDescription = r.UpdateDescription
+	event.ClusterTime = r.ClusterTime
+
+	if len(r.DocumentKey) > 0 {
+		var key struct {
+			Id ID `bson:"_id"`
+		}
+		if err := bson.Unmarshal(r.DocumentKey, &key); err != nil {
+			return event, errors.WithStack(err)
+		}
+		event.DocumentKey = key.Id
+	}
+
+	if len(r.FullDocument) > 0 {
+		if err := bson.Unmarshal(r.FullDocument, &event.FullDocument); err != nil {
+			return event, errors.WithStack(err)
+		}
+	}
+
+	if len(r.FullDocumentBeforeChange) > 0 {
+		if err := bson.Unmarshal(r.FullDocumentBeforeChange, &event.FullDocumentBeforeChange); err != nil {
+			return event, errors.WithStack(err)
+		}
+	}
+
+	return event, nil
+}
+
+func (th *subscriber[MODEL, ID]) loadResumeToken(ctx context.Context) error {
+	if th.config.Store == nil {
+		return nil
+	}
+
+	token, err := th.config.Store.Load(ctx, th.config.Name)
+	if err != nil {
+		return err
+	}
+	th.resumeToken = token
+	th.lastCheckpoint = time.Now()
+	return nil
+}
+
+// checkpoint save token if checkpoint is due, or there is any pending token when force is true.
+// token is saved with a new context, so that it is saved even if subscription is canceled
+func (th *subscriber[MODEL, ID]) checkpoint(force bool) {
+	if th.config.Store == nil || th.pending == 0 || th.resumeToken == nil {
+		return
+	}
+
+	due := force
+	if th.config.CheckpointEvents <= 0 && th.config.CheckpointInterval <= 0 {
+		due = true
+	}
+	if th.config.CheckpointEvents > 0 && th.pending >= th.config.CheckpointEvents {
+		due = true
+	}
+	if th.config.CheckpointInterval > 0 && time.Since(th.lastCheckpoint) >= th.config.CheckpointInterval {
+		due = true
+	}
+	if !due {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := th.config.Store.Save(ctx, th.config.Name, th.resumeToken); err != nil {
+		th.reportError(errors.WithMessage(err, "save resume token"))
+		return
+	}
+
+	th.pending = 0
+	th.lastCheckpoint = time.Now()
+}
+
+// isResumeTokenExpired ChangeStreamHistoryLost or ChangeStreamFatalError
+func isResumeTokenExpired(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && (serverErr.HasErrorCode(286) || serverErr.HasErrorCode(280))
+}
+
+func (th *subscriber[MODEL, ID]) reportError(err error) {
+	if th.config.OnError != nil {
+		th.config.OnError(err)
+		return
+	}
+	if DefaultLogger != nil {
+		DefaultLogger.Error(fmt.Sprintf("subscribe %s: %+v", th.name, err))
+	}
+}
@@ -0,0 +1,124 @@
+package jmgo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+func Test_Subscriber(t *testing.T) {
+	id := NewSObjectId()
+	oid, _ := primitive.ObjectIDFromHex(string(id))
+	raw, err := bson.Marshal(bson.M{
+		"_id":           bson.M{"_data": "token"},
+		"operationType": "update",
+		"documentKey":   bson.M{"_id": oid},
+		"fullDocument":  bson.M{"_id": oid, "name": "jmgo"},
+		"updateDescription": bson.M{
+			"updatedFields": bson.M{"name": "jmgo"},
+			"removedFields": bson.A{"age"},
+		},
+		"fullDocumentBeforeChange": nil,
+		"clusterTime":              primitive.Timestamp{T: 1, I: 2},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	s := &subscriber[*Test, SObjectId]{}
+	token := bson.Raw(raw).Lookup("_id").Document()
+	event, err := s.decode(raw, token)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if event.OperationType != OperationUpdate || event.DocumentKey != id || event.FullDocument == nil || event.FullDocument.Name != "jmgo" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event.FullDocumentBeforeChange != nil || event.UpdateDescription.RemovedFields[0] != "age" || event.ClusterTime.I != 2 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	// resume after the handled event without changing options of caller
+	config := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	s.config.ChangeStream = config
+	s.resumeToken = event.ResumeToken
+	opt := s.streamOptions()
+	if opt.ResumeAfter == nil || opt.StartAfter != nil || *opt.FullDocument != options.UpdateLookup || config.ResumeAfter != nil {
+		t.Fatalf("unexpected options %+v", opt)
+	}
+
+	s.invalidated = true
+	if opt = s.streamOptions(); opt.StartAfter == nil || opt.ResumeAfter != nil {
+		t.Fatalf("invalidated stream should start after token %+v", opt)
+	}
+
+	// expired token falls back to start time, and then to now if start time has expired too
+	fallback := &primitive.Timestamp{T: 1}
+	s.config.FallbackStartAtOperationTime = fallback
+	s.resumeToken, s.invalidated, s.expired = nil, false, true
+	if opt = s.streamOptions(); opt.StartAtOperationTime != fallback || opt.ResumeAfter != nil || opt.StartAfter != nil {
+		t.Fatalf("expired token should start at fallback time %+v", opt)
+	}
+
+	s.fromNow = true
+	if opt = s.streamOptions(); opt.StartAtOperationTime != nil || opt.ResumeAfter != nil || opt.StartAfter != nil {
+		t.Fatalf("expired fallback time should start from now %+v", opt)
+	}
+
+	// token of empty batch resumes the stream instead of starting from now
+	s.advance(nil)
+	if s.resumeToken != nil || !s.fromNow {
+		t.Fatal("nil token should be ignored")
+	}
+	s.advance(token)
+	if opt = s.streamOptions(); opt.ResumeAfter == nil || opt.StartAtOperationTime != nil || s.expired || s.fromNow {
+		t.Fatalf("stream should resume after token %+v", opt)
+	}
+}
+
+func Test_SubscribeRequiresName(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	db := NewDatabase(client.Database("subscribe"), nil)
+	col := NewCollection[*Test, SObjectId](&Test{}, db)
+
+	handler := func(ctx context.Context, event ChangeEvent[*Test, SObjectId]) error { return nil }
+	err = col.Subscribe(context.Background(), nil, handler, SubscribeConfig{Store: NewMemoryResumeTokenStore()})
+	if err == nil {
+		t.Fatal("subscription with store but without name should be rejected")
+	}
+
+	dbHandler := func(ctx context.Context, event ChangeEvent[bson.Raw, any]) error { return nil }
+	err = db.Subscribe(context.Background(), nil, dbHandler, SubscribeConfig{Store: NewMemoryResumeTokenStore()})
+	if err == nil {
+		t.Fatal("subscription with store but without name should be rejected")
+	}
+}
+
+func Test_DatabaseSubscriber(t *testing.T) {
+	raw, err := bson.Marshal(bson.M{
+		"_id":           bson.M{"_data": "token"},
+		"operationType": "insert",
+		"ns":            bson.M{"db": "app", "coll": "users"},
+		"documentKey":   bson.M{"_id": "1"},
+		"fullDocument":  bson.M{"_id": "1", "name": "jmgo"},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	s := &subscriber[bson.Raw, any]{}
+	event, err := s.decode(raw, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if event.Collection != "users" || event.DocumentKey != "1" || event.FullDocument.Lookup("name").StringValue() != "jmgo" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
@@ -0,0 +1,95 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"time"
+)
+
+// now current time by the clock of client
+func (th *Collection[MODEL, ID]) now() time.Time {
+	if th.client != nil && th.client.Now != nil {
+		return th.client.Now()
+	}
+	return time.Now()
+}
+
+// stampOnInsert fill createdAt and updatedAt of model if they are zero
+func (th *Collection[MODEL, ID]) stampOnInsert(model any) {
+	now := th.now()
+	for _, field := range []*entity.EntityField{th.schema.CreatedAtField, th.schema.UpdatedAtField} {
+		if field != nil {
+			th.setTimestamp(model, field, now, false)
+		}
+	}
+}
+
+// stampOnUpdate fill updatedAt of model
+func (th *Collection[MODEL, ID]) stampOnUpdate(model any, now time.Time) {
+	if th.schema.UpdatedAtField != nil {
+		th.setTimestamp(model, th.schema.UpdatedAtField, now, true)
+	}
+}
+
+// applyTimestampsToUpdate updatedAt is always set and createdAt is never overwritten,
+// updatedAt set explicitly by *UpdateBuilder is kept
+func (th *Collection[MODEL, ID]) applyTimestampsToUpdate(update bson.M, model any) {
+	createdAtField, updatedAtField := th.schema.CreatedAtField, th.schema.UpdatedAtField
+	if createdAtField == nil && updatedAtField == nil {
+		return
+	}
+
+	set, ok := update["$set"].(bson.M)
+	if !ok {
+		set = bson.M{}
+	}
+
+	if createdAtField != nil {
+		delete(set, createdAtField.DBName)
+	}
+
+	if updatedAtField != nil {
+		_, builder := model.(*UpdateBuilder)
+		if _, exists := set[updatedAtField.DBName]; !builder || !exists {
+			now := th.now()
+			th.stampOnUpdate(model, now)
+			set[updatedAtField.DBName] = timeValueOf(updatedAtField.FieldType, now)
+		}
+	}
+
+	if len(set) > 0 {
+		update["$set"] = set
+	} else {
+		delete(update, "$set")
+	}
+}
+
+// setTimestamp only pointer of MODEL can be set
+func (th *Collection[MODEL, ID]) setTimestamp(model any, field *entity.EntityField, now time.Time, overwrite bool) {
+	if _, ok := model.(MODEL); !ok {
+		return
+	}
+
+	modelValue := reflect.ValueOf(model)
+	if modelValue.Kind() != reflect.Ptr || modelValue.IsNil() {
+		return
+	}
+
+	if _, zero := field.ValueOf(modelValue); !zero && !overwrite {
+		return
+	}
+
+	value := field.ReflectValueOf(modelValue)
+	if !value.CanSet() {
+		return
+	}
+
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	value.Set(reflect.ValueOf(timeValueOf(value.Type(), now)))
+}
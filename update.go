@@ -0,0 +1,99 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fieldSelection fields to write or not to write in update, names are names defined in model
+type fieldSelection struct {
+	selects []string
+	omits   []string
+}
+
+// resolve to sets of db names
+func (th *fieldSelection) resolve(schema *entity.Entity) (selects map[string]bool, omits map[string]bool, err error) {
+	if th == nil {
+		return nil, nil, nil
+	}
+
+	lookUp := func(names []string) (map[string]bool, error) {
+		dbNames := make(map[string]bool, len(names))
+		for _, name := range names {
+			field := schema.LookUpField(name)
+			if field == nil {
+				return nil, errors.WithStack(fmt.Errorf("field %s not found in model %s", name, schema.Name))
+			}
+			dbNames[field.DBName] = true
+		}
+		return dbNames, nil
+	}
+
+	selects, err = lookUp(th.selects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	omits, err = lookUp(th.omits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return selects, omits, nil
+}
+
+// Updater update with explicit fields
+//
+//	col.Update().Select("Age", "Enabled").One(ctx, filter, model)
+type Updater[MODEL any, ID any] struct {
+	collection *Collection[MODEL, ID]
+	selection  fieldSelection
+}
+
+// Update create an updater
+func (th *Collection[MODEL, ID]) Update() *Updater[MODEL, ID] {
+	return &Updater[MODEL, ID]{collection: th}
+}
+
+// Select fields to write even if it is zero
+func (th *Updater[MODEL, ID]) Select(fields ...string) *Updater[MODEL, ID] {
+	th.selection.selects = append(th.selection.selects, fields...)
+	return th
+}
+
+// Omit fields not to write even if it is not zero
+func (th *Updater[MODEL, ID]) Omit(fields ...string) *Updater[MODEL, ID] {
+	th.selection.omits = append(th.selection.omits, fields...)
+	return th
+}
+
+func (th *Updater[MODEL, ID]) ById(ctx context.Context, id ID, model MODEL, opts ...*options.UpdateOptions) (bool, error) {
+	return th.One(ctx, bson.M{th.collection.schema.IdDBName(): id}, model, opts...)
+}
+
+func (th *Updater[MODEL, ID]) One(ctx context.Context, filter any, model MODEL, opts ...*options.UpdateOptions) (bool, error) {
+	result, err := th.collection.doUpdate(ctx, filter, model, false, &th.selection, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, err
+}
+
+func (th *Updater[MODEL, ID]) Many(ctx context.Context, filter any, model MODEL, opts ...*options.UpdateOptions) (int64, error) {
+	result, err := th.collection.doUpdate(ctx, filter, model, true, &th.selection, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, err
+}
+
+// UpdateFields update one with the given fields even if it is zero
+func (th *Collection[MODEL, ID]) UpdateFields(ctx context.Context, filter any, model MODEL, fields ...string) (bool, error) {
+	return th.Update().Select(fields...).One(ctx, filter, model)
+}
@@ -0,0 +1,224 @@
+package jmgo
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strings"
+)
+
+type updateOperation struct {
+	operator string
+	field    string
+	value    any
+}
+
+// UpdateBuilder build update document with operators, field names are names defined in model,
+// and value types are validated against field types when it is used by collection
+//
+//	col.UpdateOneWith(ctx, filter, jmgo.Update().Inc("Views", 1).Push("Tags", "x").Unset("Temp").Set("Name", v))
+type UpdateBuilder struct {
+	operations []*updateOperation
+}
+
+// Update create an update builder
+func Update() *UpdateBuilder {
+	return &UpdateBuilder{}
+}
+
+func (th *UpdateBuilder) add(operator string, field string, value any) *UpdateBuilder {
+	th.operations = append(th.operations, &updateOperation{
+		operator: operator,
+		field:    field,
+		value:    value,
+	})
+	return th
+}
+
+// Set $set
+func (th *UpdateBuilder) Set(field string, value any) *UpdateBuilder {
+	return th.add("$set", field, value)
+}
+
+// SetOnInsert $setOnInsert
+func (th *UpdateBuilder) SetOnInsert(field string, value any) *UpdateBuilder {
+	return th.add("$setOnInsert", field, value)
+}
+
+// Unset $unset
+func (th *UpdateBuilder) Unset(field string) *UpdateBuilder {
+	return th.add("$unset", field, "")
+}
+
+// Inc $inc, field and value must be number
+func (th *UpdateBuilder) Inc(field string, value any) *UpdateBuilder {
+	return th.add("$inc", field, value)
+}
+
+// Min $min
+func (th *UpdateBuilder) Min(field string, value any) *UpdateBuilder {
+	return th.add("$min", field, value)
+}
+
+// Max $max
+func (th *UpdateBuilder) Max(field string, value any) *UpdateBuilder {
+	return th.add("$max", field, value)
+}
+
+// Push $push, $each is used if there are more than one values
+func (th *UpdateBuilder) Push(field string, values ...any) *UpdateBuilder {
+	return th.add("$push", field, values)
+}
+
+// AddToSet $addToSet, $each is used if there are more than one values
+func (th *UpdateBuilder) AddToSet(field string, values ...any) *UpdateBuilder {
+	return th.add("$addToSet", field, values)
+}
+
+// Pull $pull, value can be an element or a condition of bson.M, bson.D
+func (th *UpdateBuilder) Pull(field string, value any) *UpdateBuilder {
+	return th.add("$pull", field, value)
+}
+
+// build update document, names are resolved by schema
+func (th *UpdateBuilder) build(schema *entity.Entity) (bson.M, error) {
+	update := bson.M{}
+	for _, operation := range th.operations {
+		dbName, field, err := th.resolveField(schema, operation.field)
+		if err != nil {
+			return nil, err
+		}
+
+		value := operation.value
+		// type of nested field is unknown
+		if field != nil {
+			err = th.validate(field, operation)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch operation.operator {
+		case "$push", "$addToSet":
+			values := operation.value.([]any)
+			if len(values) == 1 {
+				value = values[0]
+			} else {
+				value = bson.M{"$each": values}
+			}
+		}
+
+		fields, ok := update[operation.operator].(bson.M)
+		if !ok {
+			fields = bson.M{}
+			update[operation.operator] = fields
+		}
+		fields[dbName] = value
+	}
+
+	return update, nil
+}
+
+// resolveField field is nil if name is a nested path, only the first part of path is resolved
+func (th *UpdateBuilder) resolveField(schema *entity.Entity, name string) (string, *entity.EntityField, error) {
+	if field := schema.LookUpField(name); field != nil {
+		return field.DBName, field, nil
+	}
+
+	if index := strings.Index(name, "."); index > 0 {
+		if field := schema.LookUpField(name[:index]); field != nil {
+			return field.DBName + name[index:], nil, nil
+		}
+	}
+
+	return "", nil, errors.WithStack(fmt.Errorf("field %s not found in model %s", name, schema.Name))
+}
+
+func (th *UpdateBuilder) validate(field *entity.EntityField, operation *updateOperation) error {
+	fieldType := field.FieldType
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+
+	switch operation.operator {
+	case "$unset":
+		return nil
+	case "$inc":
+		if !isNumberKind(fieldType.Kind()) || operation.value == nil || !isNumberKind(reflect.TypeOf(operation.value).Kind()) {
+			return th.typeNotMatch(field, operation)
+		}
+		return nil
+	case "$push", "$addToSet", "$pull":
+		if fieldType.Kind() != reflect.Slice && fieldType.Kind() != reflect.Array {
+			return th.typeNotMatch(field, operation)
+		}
+
+		switch v := operation.value.(type) {
+		case []any:
+			for _, value := range v {
+				if !isValueTypeMatch(fieldType.Elem(), value) {
+					return th.typeNotMatch(field, operation)
+				}
+			}
+		case bson.M, bson.D:
+			// condition of $pull
+		default:
+			if !isValueTypeMatch(fieldType.Elem(), v) {
+				return th.typeNotMatch(field, operation)
+			}
+		}
+		return nil
+	default:
+		if !isValueTypeMatch(field.FieldType, operation.value) {
+			return th.typeNotMatch(field, operation)
+		}
+		return nil
+	}
+}
+
+func (th *UpdateBuilder) typeNotMatch(field *entity.EntityField, operation *updateOperation) error {
+	return errors.WithStack(fmt.Errorf("%w: %s %s of type %s with value %v", errortype.ErrUpdateValueTypeNotMatch,
+		operation.operator, field.Name, field.FieldType, operation.value))
+}
+
+// isValueTypeMatch value can be assigned to field type, number and string types are regarded as matched within themselves
+func isValueTypeMatch(fieldType reflect.Type, value any) bool {
+	if value == nil {
+		switch fieldType.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return true
+		}
+		return false
+	}
+
+	valueType := reflect.TypeOf(value)
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if valueType.AssignableTo(fieldType) {
+		return true
+	}
+
+	if isNumberKind(valueType.Kind()) && isNumberKind(fieldType.Kind()) {
+		return true
+	}
+
+	return valueType.Kind() == reflect.String && fieldType.Kind() == reflect.String
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
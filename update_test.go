@@ -0,0 +1,147 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_MapToFields(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+	model := &Test{Name: "abc", HelloWorld: 1}
+
+	fields, err := col.mapToFields(model, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !reflect.DeepEqual(fields, bson.M{"name": "abc", "helloWorld": 1}) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	fields, err = col.mapToFields(model, &fieldSelection{selects: []string{"Age", "Name"}})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !reflect.DeepEqual(fields, bson.M{"name": "abc", "happy": 0}) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	fields, err = col.mapToFields(model, &fieldSelection{omits: []string{"Name"}})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !reflect.DeepEqual(fields, bson.M{"helloWorld": 1}) {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+}
+
+func Test_UpdateBuilder(t *testing.T) {
+	type Article struct {
+		Id    SObjectId `bson:"_id"`
+		Name  string    `bson:"name"`
+		Views int64     `bson:"views"`
+		Tags  []string  `bson:"tags"`
+		Temp  string    `bson:"temp"`
+	}
+
+	schema, err := entity.GetOrParse(&Article{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	update, err := Update().Inc("Views", 1).Push("Tags", "x", "y").Unset("Temp").Set("Name", "abc").build(schema)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expected := bson.M{
+		"$inc":   bson.M{"views": 1},
+		"$push":  bson.M{"tags": bson.M{"$each": []any{"x", "y"}}},
+		"$unset": bson.M{"temp": ""},
+		"$set":   bson.M{"name": "abc"},
+	}
+	if !reflect.DeepEqual(update, expected) {
+		t.Fatalf("unexpected update %v", update)
+	}
+
+	for _, builder := range []*UpdateBuilder{
+		Update().Inc("Name", 1),
+		Update().Set("Views", "abc"),
+		Update().Push("Tags", 1),
+		Update().Set("NotExists", 1),
+	} {
+		if _, err := builder.build(schema); err == nil {
+			t.Fatalf("expect error for %v", builder.operations[0])
+		}
+	}
+}
+
+func Test_Version(t *testing.T) {
+	type Account struct {
+		Id      SObjectId `bson:"_id"`
+		Name    string    `bson:"name"`
+		Version int64     `bson:"version" jmgo:"version"`
+	}
+
+	schema, err := entity.GetOrParse(&Account{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Account, SObjectId]{schema: schema}
+	model := &Account{Name: "abc", Version: 3}
+
+	update, err := col.mapToUpdate(model, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col.increaseVersionInUpdate(update)
+	if !reflect.DeepEqual(update, bson.M{"$set": bson.M{"name": "abc"}, "$inc": bson.M{"version": 1}}) {
+		t.Fatalf("unexpected update %v", update)
+	}
+
+	query := col.addVersionCondition(bson.M{"name": "abc"}, model)
+	if !reflect.DeepEqual(query, bson.M{"name": "abc", "version": int64(3)}) {
+		t.Fatalf("unexpected query %v", query)
+	}
+
+	col.increaseModelVersion(model)
+	if model.Version != 4 {
+		t.Fatalf("unexpected version %d", model.Version)
+	}
+}
+
+func Test_Timestamps(t *testing.T) {
+	type Account struct {
+		Id        SObjectId `bson:"_id"`
+		Name      string    `bson:"name"`
+		CreatedAt MilliTime `bson:"createdAt" jmgo:"createdAt"`
+		UpdatedAt int64     `bson:"updatedAt" jmgo:"updatedAt"`
+	}
+
+	schema, err := entity.GetOrParse(&Account{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	now := time.UnixMilli(1700000000000)
+	col := &Collection[*Account, SObjectId]{schema: schema, client: &Client{Now: func() time.Time { return now }}}
+
+	model := &Account{Name: "abc"}
+	col.stampOnInsert(model)
+	if !time.Time(model.CreatedAt).Equal(now) || model.UpdatedAt != now.UnixMilli() {
+		t.Fatalf("unexpected timestamps %v %v", model.CreatedAt, model.UpdatedAt)
+	}
+
+	update, err := col.mapToUpdate(model, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col.applyTimestampsToUpdate(update, model)
+	if !reflect.DeepEqual(update, bson.M{"$set": bson.M{"name": "abc", "updatedAt": now.UnixMilli()}}) {
+		t.Fatalf("unexpected update %v", update)
+	}
+}
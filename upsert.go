@@ -0,0 +1,262 @@
+package jmgo
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+)
+
+type UpsertResult[ID any] struct {
+	// MatchedCount number of documents matched
+	MatchedCount int64
+	// ModifiedCount number of documents modified
+	ModifiedCount int64
+	// UpsertedCount number of documents inserted
+	UpsertedCount int64
+	// UpsertedID id of the inserted document by UpsertOne and UpsertById
+	UpsertedID ID
+	// UpsertedIDs ids of inserted documents by UpsertManyBy, the key is index of model
+	UpsertedIDs map[int64]ID
+}
+
+// Upserted whether document is inserted
+func (th *UpsertResult[ID]) Upserted() bool {
+	return th.UpsertedCount > 0
+}
+
+// UpsertById see UpsertOne
+func (th *Collection[MODEL, ID]) UpsertById(ctx context.Context, id ID, model MODEL, opts ...*options.UpdateOptions) (*UpsertResult[ID], error) {
+	return th.UpsertOne(ctx, bson.M{th.schema.IdDBName(): id}, model, opts...)
+}
+
+// UpsertOne update non-zero fields of model if document exists or else insert it.
+// BeforeUpdate is called on model, and BeforeSave is called on a copy of it whose fields are only
+// written when document is inserted. model will be replaced by the copy if document is inserted.
+// AfterSave is called if document is inserted or else AfterUpdate is called
+func (th *Collection[MODEL, ID]) UpsertOne(ctx context.Context, filter any, model MODEL, opts ...*options.UpdateOptions) (*UpsertResult[ID], error) {
+
+	query, err := th.mustConvertFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	update, inserted, err := th.makeUpsert(ctx, query, model)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, options.Update().SetUpsert(true))
+	result, err := th.collection.UpdateOne(ctx, query, update, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	upsertResult := &UpsertResult[ID]{
+		MatchedCount:  result.MatchedCount,
+		ModifiedCount: result.ModifiedCount,
+		UpsertedCount: result.UpsertedCount,
+	}
+
+	if result.UpsertedID != nil {
+		upsertResult.UpsertedID, err = th.convertId(result.UpsertedID)
+		if err != nil {
+			return nil, err
+		}
+		if err = th.writeBackId(inserted, result.UpsertedID); err != nil {
+			return nil, err
+		}
+	}
+
+	th.tryCallAfterUpsertHook(model, inserted, result.UpsertedID)
+
+	return upsertResult, nil
+}
+
+// UpsertManyBy upsert models in bulk, documents are matched by values of keyFields in model,
+// the id field is used if keyFields is empty. key fields of every model must be non-zero, or
+// ErrFilterNotContainAnyCondition is returned, so insert new models without id instead. Hooks are called as UpsertOne
+func (th *Collection[MODEL, ID]) UpsertManyBy(ctx context.Context, models []MODEL, keyFields ...string) (*UpsertResult[ID], error) {
+
+	if len(models) == 0 {
+		return &UpsertResult[ID]{}, nil
+	}
+
+	if len(keyFields) == 0 {
+		keyFields = []string{th.schema.IdField.Name}
+	}
+
+	var writeModels = make([]mongo.WriteModel, 0, len(models))
+	var insertedModels = make([]MODEL, 0, len(models))
+	for _, model := range models {
+		query, err := th.makeKeyFilter(model, keyFields)
+		if err != nil {
+			return nil, err
+		}
+
+		update, inserted, err := th.makeUpsert(ctx, query, model)
+		if err != nil {
+			return nil, err
+		}
+
+		writeModels = append(writeModels, mongo.NewUpdateOneModel().SetFilter(query).SetUpdate(update).SetUpsert(true))
+		insertedModels = append(insertedModels, inserted)
+	}
+
+	result, err := th.collection.BulkWrite(ctx, writeModels)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	upsertResult := &UpsertResult[ID]{
+		MatchedCount:  result.MatchedCount,
+		ModifiedCount: result.ModifiedCount,
+		UpsertedCount: result.UpsertedCount,
+		UpsertedIDs:   make(map[int64]ID, len(result.UpsertedIDs)),
+	}
+
+	for i, model := range models {
+		upsertedId := result.UpsertedIDs[int64(i)]
+		if upsertedId != nil {
+			id, err := th.convertId(upsertedId)
+			if err != nil {
+				return nil, err
+			}
+			upsertResult.UpsertedIDs[int64(i)] = id
+			if err = th.writeBackId(insertedModels[i], upsertedId); err != nil {
+				return nil, err
+			}
+		}
+		th.tryCallAfterUpsertHook(model, insertedModels[i], upsertedId)
+	}
+
+	return upsertResult, nil
+}
+
+// makeUpsert fields of model are set, and fields only exist in inserted model are set on insert,
+// updatedAt is filled on model and createdAt is filled on inserted model.
+// version is increased but not checked, because document is inserted if it does not match.
+// id is generated by id generator of collection on inserted model unless id is specified in query
+func (th *Collection[MODEL, ID]) makeUpsert(ctx context.Context, query any, model MODEL) (bson.M, MODEL, error) {
+
+	var inserted MODEL
+
+	err := th.tryCallBeforeUpdateHook(model)
+	if err != nil {
+		return nil, inserted, err
+	}
+
+	th.stampOnUpdate(model, th.now())
+
+	inserted = th.cloneModel(model)
+	// id in filter is used by the inserted document
+	if !hasCondition(query, th.schema.IdDBName()) {
+		if err := th.assignId(ctx, inserted, false); err != nil {
+			return nil, inserted, err
+		}
+	}
+	th.stampOnInsert(inserted)
+	if d, ok := any(inserted).(BeforeSave); ok {
+		if err := d.BeforeSave(); err != nil {
+			return nil, inserted, err
+		}
+	}
+
+	// validate the document might be inserted
+	if err := th.validate(inserted); err != nil {
+		return nil, inserted, errors.WithStack(err)
+	}
+
+	set, err := th.mapToFields(model, nil)
+	if err != nil {
+		return nil, inserted, err
+	}
+	insertFields, err := th.mapToFields(inserted, nil)
+	if err != nil {
+		return nil, inserted, err
+	}
+	// createdAt is only set on insert
+	if th.schema.CreatedAtField != nil {
+		delete(set, th.schema.CreatedAtField.DBName)
+	}
+	setOnInsert := bson.M{}
+	for k, v := range insertFields {
+		// same path can not be both in $set and $setOnInsert
+		if _, ok := set[k]; !ok {
+			setOnInsert[k] = v
+		}
+	}
+
+	// version is increased by $inc, it is 1 if document is inserted
+	if th.schema.VersionField != nil {
+		delete(setOnInsert, th.schema.VersionField.DBName)
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(setOnInsert) > 0 {
+		update["$setOnInsert"] = setOnInsert
+	}
+	if th.schema.VersionField != nil {
+		th.increaseVersionInUpdate(update)
+		th.setModelVersion(inserted, 1)
+	}
+
+	return update, inserted, nil
+}
+
+// makeKeyFilter make filter by values of key fields in model, all key fields must be non-zero
+func (th *Collection[MODEL, ID]) makeKeyFilter(model MODEL, keyFields []string) (bson.M, error) {
+	value := reflect.ValueOf(model)
+	query := bson.M{}
+	for _, keyField := range keyFields {
+		field, err := th.mustSchemaField(keyField)
+		if err != nil {
+			return nil, err
+		}
+		v, zero := field.ValueOf(value)
+		// zero key, e.g. id of new model, would match the document upserted by another model with zero key
+		if zero {
+			return nil, errors.WithStack(fmt.Errorf("%w: key field %s is zero", errortype.ErrFilterNotContainAnyCondition, keyField))
+		}
+		query[field.DBName] = v
+	}
+
+	if len(query) == 0 {
+		return nil, errors.WithStack(errortype.ErrFilterNotContainAnyCondition)
+	}
+
+	return query, nil
+}
+
+// cloneModel shallow copy model
+func (th *Collection[MODEL, ID]) cloneModel(model MODEL) MODEL {
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return model
+	}
+
+	clone := reflect.New(value.Elem().Type())
+	clone.Elem().Set(value.Elem())
+	return clone.Interface().(MODEL)
+}
+
+func (th *Collection[MODEL, ID]) tryCallAfterUpsertHook(model MODEL, inserted MODEL, upsertedId any) {
+	if upsertedId == nil {
+		th.tryCallAfterUpdateHook(model)
+		return
+	}
+
+	// model takes fields set by BeforeSave if document is inserted
+	value := reflect.ValueOf(model)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value.Elem().Set(reflect.ValueOf(inserted).Elem())
+	}
+	th.tryCallAfterSaveHook(model, upsertedId)
+}
@@ -0,0 +1,119 @@
+package jmgo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/wsk-go/jmgo/entity"
+	"github.com/wsk-go/jmgo/errortype"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_MakeKeyFilter(t *testing.T) {
+	schema, err := entity.GetOrParse(&Test{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Test, SObjectId]{schema: schema}
+
+	query, err := col.makeKeyFilter(&Test{Id: "1", Name: "abc"}, []string{"Id"})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !reflect.DeepEqual(query, bson.M{"_id": SObjectId("1")}) {
+		t.Fatalf("unexpected filter %v", query)
+	}
+
+	query, err = col.makeKeyFilter(&Test{Name: "abc", Age: 3}, []string{"Name", "Age"})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !reflect.DeepEqual(query, bson.M{"name": "abc", "happy": 3}) {
+		t.Fatalf("unexpected filter %v", query)
+	}
+
+	// new models without id would all match the document upserted by the first one
+	_, err = col.makeKeyFilter(&Test{Name: "abc"}, []string{"Id"})
+	if !errors.Is(err, errortype.ErrFilterNotContainAnyCondition) {
+		t.Fatalf("expected ErrFilterNotContainAnyCondition for zero id, got %v", err)
+	}
+
+	_, err = col.makeKeyFilter(&Test{Name: "abc"}, []string{"Name", "Age"})
+	if !errors.Is(err, errortype.ErrFilterNotContainAnyCondition) {
+		t.Fatalf("expected ErrFilterNotContainAnyCondition for zero key, got %v", err)
+	}
+
+	_, err = col.makeKeyFilter(&Test{Name: "abc"}, []string{"Missing"})
+	if err == nil {
+		t.Fatal("expected error for unknown key field")
+	}
+}
+
+func Test_MakeUpsert(t *testing.T) {
+	type Article struct {
+		Id        SObjectId `bson:"_id,omitempty"`
+		Name      string    `bson:"name"`
+		Views     int64     `bson:"views"`
+		CreatedAt time.Time `bson:"createdAt" jmgo:"createdAt"`
+		UpdatedAt time.Time `bson:"updatedAt" jmgo:"updatedAt"`
+	}
+
+	schema, err := entity.GetOrParse(&Article{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	col := &Collection[*Article, SObjectId]{schema: schema, client: &Client{Now: func() time.Time { return now }}}
+
+	model := &Article{Name: "abc"}
+	update, inserted, err := col.makeUpsert(context.Background(), bson.M{"name": "abc"}, model)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	expected := bson.M{
+		"$set":         bson.M{"name": "abc", "updatedAt": now},
+		"$setOnInsert": bson.M{"createdAt": now},
+	}
+	if !reflect.DeepEqual(update, expected) {
+		t.Fatalf("unexpected update %v", update)
+	}
+	if !model.CreatedAt.IsZero() || !model.UpdatedAt.Equal(now) {
+		t.Fatalf("only updatedAt should be stamped on model, got %+v", model)
+	}
+	if inserted == model || !inserted.CreatedAt.Equal(now) {
+		t.Fatalf("createdAt should be stamped on a copy of model, got %+v", inserted)
+	}
+}
+
+func Test_MakeUpsertVersion(t *testing.T) {
+	type Account struct {
+		Id      SObjectId `bson:"_id,omitempty"`
+		Name    string    `bson:"name"`
+		Version int64     `bson:"version" jmgo:"version"`
+	}
+
+	schema, err := entity.GetOrParse(&Account{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	col := &Collection[*Account, SObjectId]{schema: schema, client: &Client{}}
+
+	// loaded version is never written back, it is increased instead
+	update, inserted, err := col.makeUpsert(context.Background(), bson.M{"_id": "1"}, &Account{Id: "1", Name: "abc", Version: 3})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expected := bson.M{
+		"$set": bson.M{"_id": SObjectId("1"), "name": "abc"},
+		"$inc": bson.M{"version": 1},
+	}
+	if !reflect.DeepEqual(update, expected) {
+		t.Fatalf("unexpected update %v", update)
+	}
+	if inserted.Version != 1 {
+		t.Fatalf("inserted document has version 1, got %d", inserted.Version)
+	}
+}
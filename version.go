@@ -0,0 +1,135 @@
+package jmgo
+
+import (
+	"github.com/wsk-go/jmgo/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+)
+
+// isVersioned model has version field and update is made from model
+func (th *Collection[MODEL, ID]) isVersioned(model any) bool {
+	if th.schema.VersionField == nil {
+		return false
+	}
+	_, ok := model.(MODEL)
+	return ok
+}
+
+// versionOf loaded version of model
+func (th *Collection[MODEL, ID]) versionOf(model any) int64 {
+	value := reflect.Indirect(th.schema.VersionField.ReflectValueOf(reflect.ValueOf(model)))
+	switch {
+	case !value.IsValid():
+		return 0
+	case value.CanInt():
+		return value.Int()
+	default:
+		return int64(value.Uint())
+	}
+}
+
+// addVersionCondition document must have the version loaded in model, missing version is regarded as zero
+func (th *Collection[MODEL, ID]) addVersionCondition(query any, model any) any {
+	field := th.schema.VersionField
+	version := th.versionOf(model)
+
+	var condition any = version
+	if version == 0 {
+		condition = bson.M{"$in": bson.A{nil, 0}}
+	}
+
+	return addCondition(query, field.DBName, condition)
+}
+
+// increaseVersionInUpdate version is increased by $inc instead of being set
+func (th *Collection[MODEL, ID]) increaseVersionInUpdate(update bson.M) {
+	field := th.schema.VersionField
+	if set, ok := update["$set"].(bson.M); ok {
+		delete(set, field.DBName)
+		if len(set) == 0 {
+			delete(update, "$set")
+		}
+	}
+	update["$inc"] = bson.M{field.DBName: 1}
+}
+
+// increaseVersionInReplacement replacement has the next version
+func (th *Collection[MODEL, ID]) increaseVersionInReplacement(replacement bson.D, model any) bson.D {
+	field := th.schema.VersionField
+	version := th.versionOf(model) + 1
+	for i, e := range replacement {
+		if e.Key == field.DBName {
+			replacement[i].Value = version
+			return replacement
+		}
+	}
+	return append(replacement, bson.E{Key: field.DBName, Value: version})
+}
+
+// increaseModelVersion keep version of model the same as the document after it is written
+func (th *Collection[MODEL, ID]) increaseModelVersion(model any) {
+	th.setModelVersion(model, th.versionOf(model)+1)
+}
+
+func (th *Collection[MODEL, ID]) setModelVersion(model any, version int64) {
+	value := th.schema.VersionField.ReflectValueOf(reflect.ValueOf(model))
+	if !value.CanSet() {
+		return
+	}
+
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	if entity.IsIntegerType(value.Type()) && value.CanInt() {
+		value.SetInt(version)
+	} else {
+		value.SetUint(uint64(version))
+	}
+}
+
+// addCondition add condition to query, $and is used if the key already exists in query
+func addCondition(query any, key string, condition any) any {
+	switch q := query.(type) {
+	case bson.M:
+		if _, ok := q[key]; ok {
+			break
+		}
+		added := make(bson.M, len(q)+1)
+		for k, v := range q {
+			added[k] = v
+		}
+		added[key] = condition
+		return added
+	case bson.D:
+		for _, e := range q {
+			if e.Key == key {
+				return bson.M{"$and": bson.A{query, bson.M{key: condition}}}
+			}
+		}
+		added := make(bson.D, 0, len(q)+1)
+		added = append(added, q...)
+		return append(added, bson.E{Key: key, Value: condition})
+	}
+
+	return bson.M{"$and": bson.A{query, bson.M{key: condition}}}
+}
+
+// hasCondition whether the key exists at the top level of query
+func hasCondition(query any, key string) bool {
+	switch q := query.(type) {
+	case bson.M:
+		_, ok := q[key]
+		return ok
+	case bson.D:
+		for _, e := range q {
+			if e.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}